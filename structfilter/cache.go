@@ -0,0 +1,234 @@
+package structfilter
+
+import (
+	"container/list"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// CacheStats describes the type cache of a structure filter, see T.Stats.
+type CacheStats struct {
+	// Hits is the number of times a filtered structure type was found in the
+	// cache.
+	Hits int64
+
+	// Misses is the number of times a structure type had to be filtered
+	// because it was not in the cache.
+	Misses int64
+
+	// BuildTime is the total time spent filtering structure types, including
+	// the time spent in filter functions.
+	BuildTime time.Duration
+
+	// Cached is the number of filtered structure types currently in the
+	// cache, counting each profile separately, and each path separately for
+	// types depending on the path, see Field.Path.
+	Cached int
+
+	// Roots is the number of root types currently in the cache, see
+	// T.SetCacheLimit.
+	Roots int
+
+	// Created is the number of filtered structure types created so far.
+	// Types created with the reflect package are never freed, so this is an
+	// upper bound of the number of filtered types retained in memory, even
+	// after they have been discarded from the cache. The bound is not tight,
+	// as the reflect package reuses identical types.
+	Created int64
+}
+
+// rootKey identifies a root type in the type cache.
+type rootKey struct {
+	// profile is the profile the type has been filtered for.
+	profile string
+
+	// typ is the original type.
+	typ reflect.Type
+}
+
+// Stats returns statistics about the type cache of t.
+func (t *T) Stats() CacheStats {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	stats := t.stats
+	for _, types := range t.types {
+		stats.Cached += len(types)
+	}
+	stats.Roots = len(t.rootElems)
+	return stats
+}
+
+// CachedTypes returns the original structure types whose filtered types are
+// currently in the cache, for any profile or path, ordered by their string
+// representation.
+func (t *T) CachedTypes() []reflect.Type {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	seen := make(map[reflect.Type]bool)
+	var result []reflect.Type
+	for _, types := range t.types {
+		for key := range types {
+			if !seen[key.typ] {
+				seen[key.typ] = true
+				result = append(result, key.typ)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+// Reset discards all filtered types created so far. Filter functions will be
+// called again for all structure types converted afterwards. Reset does not
+// reset the statistics returned by Stats.
+func (t *T) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.resetTypes()
+}
+
+// Forget discards the filtered types for the specified structure type, or
+// pointer to structure type, for all profiles and paths. Since filtered types
+// refer to each other, Forget also discards the filtered types of all
+// structure types referring to typ. Filter functions will be called again for
+// these types when they are converted next.
+func (t *T) Forget(typ reflect.Type) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	structType, _ := getStructType(typ)
+	if structType == nil {
+		return
+	}
+	for profile, types := range t.types {
+		for key := range types {
+			if reachableTypes(key.typ)[structType] {
+				delete(types, key)
+			}
+		}
+		t.forgetRoots(profile, func(root reflect.Type) bool {
+			return reachableTypes(root)[structType]
+		})
+	}
+}
+
+// SetCacheLimit sets the maximum number of root types in the type cache. A
+// root type is a type passed to T.ReflectType or T.MapType, or the type of a
+// value passed to T.Convert, T.EncodeJSON, or T.Precompile, counted
+// separately for each profile. If the limit is exceeded, the least recently
+// used root types are discarded from the cache, along with all filtered types
+// no longer reachable from the remaining root types. A limit of zero, the
+// default, means no limit.
+//
+// Note that the limit does not bound the memory used for filtered types, as
+// types created with the reflect package are never freed, see
+// CacheStats.Created. However, with filter functions making the same
+// decisions each time, discarded types are recreated identically, and the
+// reflect package reuses identical types.
+func (t *T) SetCacheLimit(n int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	t.cacheLimit = n
+	t.enforceCacheLimit()
+}
+
+// useRoot marks the specified original type as the most recently used root
+// type for the specified profile, and enforces the cache limit. useRoot must
+// only be called before mapping a type from scratch, as it may discard
+// filtered types.
+func (t *T) useRoot(profile string, typ reflect.Type) {
+	if t.rootElems == nil {
+		t.roots = list.New()
+		t.rootElems = make(map[rootKey]*list.Element)
+	}
+	key := rootKey{profile: profile, typ: typ}
+	if elem, ok := t.rootElems[key]; ok {
+		t.roots.MoveToBack(elem)
+		return
+	}
+	t.rootElems[key] = t.roots.PushBack(key)
+	t.enforceCacheLimit()
+}
+
+// enforceCacheLimit discards the least recently used root types until the
+// cache limit is satisfied.
+func (t *T) enforceCacheLimit() {
+	if t.cacheLimit == 0 || len(t.rootElems) <= t.cacheLimit {
+		return
+	}
+	for len(t.rootElems) > t.cacheLimit {
+		key := t.roots.Remove(t.roots.Front()).(rootKey)
+		delete(t.rootElems, key)
+	}
+	// Keep exactly the types reachable from the remaining roots, so that no
+	// cached type refers to a discarded one.
+	reachable := make(map[string]map[reflect.Type]bool)
+	for key := range t.rootElems {
+		if reachable[key.profile] == nil {
+			reachable[key.profile] = make(map[reflect.Type]bool)
+		}
+		markReachable(key.typ, reachable[key.profile])
+	}
+	for profile, types := range t.types {
+		for key := range types {
+			if !reachable[profile][key.typ] {
+				delete(types, key)
+			}
+		}
+		if len(types) == 0 {
+			delete(t.types, profile)
+		}
+	}
+}
+
+// forgetRoots removes the root types for the specified profile for which
+// forget reports true.
+func (t *T) forgetRoots(profile string, forget func(reflect.Type) bool) {
+	for key, elem := range t.rootElems {
+		if key.profile == profile && forget(key.typ) {
+			t.roots.Remove(elem)
+			delete(t.rootElems, key)
+		}
+	}
+}
+
+// reachableTypes returns the set of structure types reachable from typ
+// without going through an interface, including typ itself, see
+// markReachable.
+func reachableTypes(typ reflect.Type) map[reflect.Type]bool {
+	result := make(map[reflect.Type]bool)
+	markReachable(typ, result)
+	return result
+}
+
+// markReachable adds the structure types reachable from typ without going
+// through an interface to seen. Named types of other kinds are added as well,
+// as they can be recursive, too.
+func markReachable(typ reflect.Type, seen map[reflect.Type]bool) {
+	if isNamedContainer(typ) {
+		if seen[typ] {
+			return
+		}
+		seen[typ] = true
+	}
+	switch typ.Kind() {
+	case reflect.Array, reflect.Ptr, reflect.Slice:
+		markReachable(typ.Elem(), seen)
+	case reflect.Map:
+		markReachable(typ.Key(), seen)
+		markReachable(typ.Elem(), seen)
+	case reflect.Struct:
+		if seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i != typ.NumField(); i++ {
+			markReachable(typ.Field(i).Type, seen)
+		}
+	}
+}
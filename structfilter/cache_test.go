@@ -0,0 +1,132 @@
+package structfilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cachedTypeNames returns the string representations of the cached types of
+// filter.
+func cachedTypeNames(filter *T) []string {
+	var names []string
+	for _, typ := range filter.CachedTypes() {
+		names = append(names, typ.String())
+	}
+	return names
+}
+
+// TestCacheStats tests the cache statistics.
+func TestCacheStats(t *testing.T) {
+	var calls int
+	filter := New(func(*Field) error {
+		calls++
+		return nil
+	})
+	for i := 0; i != 3; i++ {
+		if _, err := filter.Convert(testTeam); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := filter.Stats()
+	if stats.Misses != 2 || stats.Hits != 2 || stats.Created != 2 ||
+		stats.Cached != 2 || stats.Roots != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.BuildTime <= 0 {
+		t.Error("Expected positive build time")
+	}
+	if calls != 7 {
+		t.Errorf("Expected 7 filter calls, got %d", calls)
+	}
+	expected := []string{"structfilter.Member", "structfilter.Team"}
+	if names := cachedTypeNames(filter); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cached types %v, got %v", expected, names)
+	}
+	filter.Reset()
+	if names := cachedTypeNames(filter); len(names) != 0 {
+		t.Errorf("Expected empty cache after reset, got %v", names)
+	}
+	stats = filter.Stats()
+	if stats.Cached != 0 || stats.Roots != 0 || stats.Created != 2 {
+		t.Errorf("Unexpected stats after reset: %+v", stats)
+	}
+}
+
+// TestForget tests discarding single types from the cache.
+func TestForget(t *testing.T) {
+	filter := New()
+	if _, err := filter.Convert(&testTeam); err != nil {
+		t.Fatal(err)
+	}
+	filter.Forget(reflect.TypeOf(&Team{}))
+	expected := []string{"structfilter.Member"}
+	if names := cachedTypeNames(filter); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cached types %v, got %v", expected, names)
+	}
+	if _, err := filter.Convert(testTeam); err != nil {
+		t.Fatal(err)
+	}
+	filter.Forget(reflect.TypeOf(Member{}))
+	if names := cachedTypeNames(filter); len(names) != 0 {
+		t.Errorf("Expected referring types to be forgotten, got %v", names)
+	}
+	filter.Forget(reflect.TypeOf(0))
+	if _, err := filter.Convert(testTeam); err != nil {
+		t.Fatal(err)
+	}
+	if stats := filter.Stats(); stats.Misses != 5 || stats.Roots != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestCacheLimit tests bounding the number of cached root types.
+func TestCacheLimit(t *testing.T) {
+	filter := New()
+	filter.SetCacheLimit(2)
+	for _, v := range []interface{}{
+		testTeam, []Member{{}}, testTeam, Credentials{},
+	} {
+		if _, err := filter.Convert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Member is still reachable from Team.
+	expected := []string{
+		"structfilter.Credentials", "structfilter.Member", "structfilter.Team",
+	}
+	if names := cachedTypeNames(filter); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cached types %v, got %v", expected, names)
+	}
+	filter.SetCacheLimit(1)
+	expected = []string{"structfilter.Credentials"}
+	if names := cachedTypeNames(filter); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cached types %v, got %v", expected, names)
+	}
+	if stats := filter.Stats(); stats.Roots != 1 {
+		t.Errorf("Expected 1 root, got %d", stats.Roots)
+	}
+	// Converting evicted types still works.
+	result := convertJSON(t, filter, testTeam)
+	expectedJSON := `{"Name":"core","Token":"t1","Lead":{"Name":"alice",` +
+		`"Email":"alice@example.com","Token":"t2"},"Internal":"x"}`
+	if result != expectedJSON {
+		t.Errorf("Expected %s, got %s", expectedJSON, result)
+	}
+}
+
+// TestCacheLimitRedact tests that redaction survives evictions.
+func TestCacheLimitRedact(t *testing.T) {
+	filter := New(When(NameMatches(Exact("Email")), redactFilter))
+	filter.SetCacheLimit(1)
+	for i := 0; i != 2; i++ {
+		if _, err := filter.Convert(Member{}); err != nil {
+			t.Fatal(err)
+		}
+		result := convertJSON(t, filter, testTeam)
+		expected := `{"Name":"core","Token":"t1","Lead":{"Name":"alice",` +
+			`"Email":"[REDACTED]","Token":"t2"},"Internal":"x"}`
+		if result != expected {
+			t.Errorf("Expected %s, got %s", expected, result)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package structfilter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldPredicate is a function type for deciding whether a filter function
+// applies to a field, see When and Unless.
+type FieldPredicate func(*Field) bool
+
+// NameMatches returns a field predicate which reports whether the name of a
+// field matches m.
+func NameMatches(m Matcher) FieldPredicate {
+	return func(f *Field) bool {
+		return m.MatchString(f.Name())
+	}
+}
+
+// PathMatches returns a field predicate which reports whether the path of a
+// field matches m, see Field.Path and PathPattern.
+func PathMatches(m Matcher) FieldPredicate {
+	return func(f *Field) bool {
+		return m.MatchString(f.Path())
+	}
+}
+
+// When returns a filter function which calls filter only for fields for which
+// pred reports true.
+func When(pred FieldPredicate, filter Func) Func {
+	return func(f *Field) error {
+		if !pred(f) {
+			return nil
+		}
+		return filter(f)
+	}
+}
+
+// Unless returns a filter function which calls filter only for fields for
+// which pred reports false.
+func Unless(pred FieldPredicate, filter Func) Func {
+	return func(f *Field) error {
+		if pred(f) {
+			return nil
+		}
+		return filter(f)
+	}
+}
+
+// FirstMatch returns a filter function which calls the specified filter
+// functions in order until one of them calls Keep, Remove, Redact, or Stop on
+// the field. The remaining filter functions are not called. This allows
+// policies to be listed by precedence, independently of the decisions made by
+// filter functions before FirstMatch.
+func FirstMatch(filters ...Func) Func {
+	return func(f *Field) error {
+		decided := f.decided
+		for i, filter := range filters {
+			f.decided = false
+			err := filter(f)
+			matched := f.decided || f.stopped
+			f.decided = f.decided || decided
+			if err != nil {
+				return fmt.Errorf("filter[%d]: %w", i, err)
+			}
+			if matched {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// ForTypes returns a filter function which calls filter only for the fields
+// of the specified original structure types, see Field.StructType.
+func ForTypes(types []reflect.Type, filter Func) Func {
+	set := make(map[reflect.Type]struct{}, len(types))
+	for _, typ := range types {
+		set[typ] = struct{}{}
+	}
+	return func(f *Field) error {
+		if _, ok := set[f.StructType()]; !ok {
+			return nil
+		}
+		return filter(f)
+	}
+}
+
+// Scoped returns a filter function which calls filter only for the field at
+// the specified path and the fields below it, see Field.Path. For example,
+// with a path of "User.Credentials", filter is called for the fields
+// "User.Credentials" and "User.Credentials.Password", but not for
+// "User.CredentialsExpiry".
+func Scoped(path string, filter Func) Func {
+	prefix := path + "."
+	return func(f *Field) error {
+		if p := f.Path(); p != path && !strings.HasPrefix(p, prefix) {
+			return nil
+		}
+		return filter(f)
+	}
+}
+
+// Stop is a filter function which calls Stop on every field, so that no
+// further filter functions are called. It is mostly useful with When and
+// Unless, e. g., to exempt certain fields from all later filter functions.
+func Stop(f *Field) error {
+	f.Stop()
+	return nil
+}
@@ -0,0 +1,189 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Team is a structure type for testing filter combinators.
+type Team struct {
+	Name     string
+	Token    string
+	Lead     Member
+	Internal string
+}
+
+// Member is a structure type for testing filter combinators.
+type Member struct {
+	Name  string
+	Email string
+	Token string
+}
+
+// testTeam is a team value for testing filter combinators.
+var testTeam = Team{
+	Name:     "core",
+	Token:    "t1",
+	Lead:     Member{Name: "alice", Email: "alice@example.com", Token: "t2"},
+	Internal: "x",
+}
+
+// removeFilter is a filter function which removes all fields.
+func removeFilter(f *Field) error {
+	f.Remove()
+	return nil
+}
+
+// keepFilter is a filter function which keeps all fields.
+func keepFilter(f *Field) error {
+	f.Keep()
+	return nil
+}
+
+// redactFilter is a filter function which redacts all fields.
+func redactFilter(f *Field) error {
+	f.Redact()
+	return nil
+}
+
+// convertJSON converts v with filter and returns the JSON encoding of the
+// result, or fails the test.
+func convertJSON(t *testing.T, filter *T, v interface{}) string {
+	t.Helper()
+	converted, err := filter.Convert(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestWhenUnless tests the When and Unless combinators.
+func TestWhenUnless(t *testing.T) {
+	filter := New(
+		When(NameMatches(Exact("Token")), removeFilter),
+		Unless(PathMatches(Glob("Lead*")), When(NameMatches(Exact("Internal")),
+			redactFilter)),
+	)
+	expected := `{"Name":"core","Lead":{"Name":"alice",` +
+		`"Email":"alice@example.com"},"Internal":"[REDACTED]"}`
+	if result := convertJSON(t, filter, testTeam); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+// TestFirstMatch tests the FirstMatch combinator.
+func TestFirstMatch(t *testing.T) {
+	filter := New(
+		removeFilter,
+		FirstMatch(
+			When(NameMatches(Exact("Email")), redactFilter),
+			When(NameMatches(Glob("*Name")), keepFilter),
+			When(NameMatches(Exact("Token")), Stop),
+			When(NameMatches(Any(Exact("Name"), Exact("Token"))), keepFilter),
+			When(PathMatches(PathPattern("**")), keepFilter),
+		),
+	)
+	filter.SetDefault(DefaultError)
+	expected := `{"Name":"core","Lead":{"Name":"alice","Email":"[REDACTED]"},` +
+		`"Internal":"x"}`
+	if result := convertJSON(t, filter, testTeam); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+	// A filter deciding nothing keeps earlier decisions.
+	filter = New(FirstMatch(nopFilter))
+	filter.SetDefault(DefaultError)
+	if _, err := filter.Convert(testTeam); !errors.Is(err, ErrUndecided) {
+		t.Errorf("Expected ErrUndecided, got %v", err)
+	}
+	filter = New(keepFilter, FirstMatch(nopFilter))
+	filter.SetDefault(DefaultError)
+	if _, err := filter.Convert(testTeam); err != nil {
+		t.Error(err)
+	}
+	// Errors
+	filter = New(FirstMatch(nopFilter, errorFilter, keepFilter))
+	if _, err := filter.Convert(testTeam); !errors.Is(err, errFilter) {
+		t.Errorf("Expected filter error, got %v", err)
+	}
+}
+
+// TestForTypesScoped tests the ForTypes and Scoped combinators.
+func TestForTypesScoped(t *testing.T) {
+	filter := New(
+		ForTypes([]reflect.Type{reflect.TypeOf(Member{})},
+			When(NameMatches(Exact("Token")), removeFilter)),
+		Scoped("Lead", When(NameMatches(Exact("Email")), redactFilter)),
+		Scoped("Internal", removeFilter),
+	)
+	expected := `{"Name":"core","Token":"t1","Lead":{"Name":"alice",` +
+		`"Email":"[REDACTED]"}}`
+	if result := convertJSON(t, filter, testTeam); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+// Squad is a structure type reaching Member through a different path than
+// Team.
+type Squad struct {
+	Captain Member
+}
+
+// TestScopedOrder tests that Scoped does not depend on the order in which
+// types are converted.
+func TestScopedOrder(t *testing.T) {
+	filter := New(Scoped("Lead", RemoveFieldFilter(Exact("Email"))))
+	squad := Squad{Captain: testTeam.Lead}
+	expected := `{"Captain":{"Name":"alice","Email":"alice@example.com",` +
+		`"Token":"t2"}}`
+	if result := convertJSON(t, filter, squad); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+	expected = `{"Name":"core","Token":"t1","Lead":{"Name":"alice",` +
+		`"Token":"t2"},"Internal":"x"}`
+	if result := convertJSON(t, filter, testTeam); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+// TestStop tests stopping the filter chain.
+func TestStop(t *testing.T) {
+	var calls int
+	counter := func(*Field) error {
+		calls++
+		return nil
+	}
+	filter := New(
+		When(NameMatches(Exact("Token")), removeFilter),
+		When(PathMatches(Exact("Lead.Token")), Stop),
+		counter,
+		keepFilter,
+	)
+	expected := `{"Name":"core","Token":"t1","Lead":{"Name":"alice",` +
+		`"Email":"alice@example.com"},"Internal":"x"}`
+	if result := convertJSON(t, filter, testTeam); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+	if calls != 6 {
+		t.Errorf("Expected 6 calls, got %d", calls)
+	}
+}
+
+// TestFieldTypes tests the type information of fields.
+func TestFieldTypes(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.StructType() == reflect.TypeOf(Team{}) && f.Name() == "Lead" &&
+			f.Type() != reflect.TypeOf(Member{}) {
+			t.Errorf("Unexpected type for Lead: %s", f.Type())
+		}
+		return nil
+	})
+	if _, err := filter.Convert(testTeam); err != nil {
+		t.Fatal(err)
+	}
+}
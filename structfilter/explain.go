@@ -0,0 +1,235 @@
+package structfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Report describes what a structure filter does to a type graph. A report is
+// obtained with T.Explain. It can be rendered as text with its String method,
+// or as JSON with the encoding/json package.
+type Report struct {
+	// Type is the original type the report was created for.
+	Type string `json:"type"`
+
+	// Filtered is the filtered type. It is empty if Dropped is true.
+	Filtered string `json:"filtered"`
+
+	// Dropped reports whether values of Type are dropped altogether, i. e.,
+	// converted to nil, because of the non-data policy or a type function.
+	Dropped bool `json:"dropped,omitempty"`
+
+	// Structs describes every structure type reachable from Type without
+	// going through an interface, in the order they were first encountered.
+	Structs []StructReport `json:"structs"`
+}
+
+// StructReport describes how a single structure type has been filtered.
+type StructReport struct {
+	// Type is the original structure type.
+	Type string `json:"type"`
+
+	// Path is the path the structure type has been filtered at, if its
+	// filtered type depends on the path, see Field.Path. A structure type
+	// may then be reported once for each path.
+	Path string `json:"path,omitempty"`
+
+	// Fields describes the exported fields of the original structure type.
+	// Unexported fields are always removed and therefore not listed.
+	Fields []FieldReport `json:"fields"`
+}
+
+// FieldReport describes how a single structure field has been filtered.
+type FieldReport struct {
+	// Name is the name of the field.
+	Name string `json:"name"`
+
+	// Type is the original type of the field.
+	Type string `json:"type"`
+
+	// FilteredType is the type of the field in the filtered structure. It is
+	// empty if the field has been removed.
+	FilteredType string `json:"filteredType,omitempty"`
+
+	// Kept reports whether the field is part of the filtered structure.
+	Kept bool `json:"kept"`
+
+	// DecidedBy is the index of the filter function which made the final
+	// decision to keep or remove the field, or -1 if the default applied.
+	DecidedBy int `json:"decidedBy"`
+
+	// TagBefore is the original tag of the field.
+	TagBefore string `json:"tagBefore"`
+
+	// TagAfter is the tag of the field after all filter functions have run.
+	TagAfter string `json:"tagAfter"`
+
+	// RetaggedBy is the index of the filter function which last changed the
+	// tag, or -1 if the tag has not been changed.
+	RetaggedBy int `json:"retaggedBy"`
+
+	// Recursive reports whether recursion has been cut somewhere in the field
+	// type, see Recursion.
+	Recursive bool `json:"recursive,omitempty"`
+
+	// NonData reports whether the field has been removed because its type
+	// involves a non-data kind, see NonDataDrop.
+	NonData bool `json:"nonData,omitempty"`
+
+	// Sensitive reports whether the field has been removed because its type
+	// is removed by a type function or a sensitive type registration, see
+	// TypeFunc.
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// Redacted reports whether the field value is replaced with Redacted, see
+	// Field.Redact.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// Explain reports what t does to the specified original type and all
+// structure types reachable from it. orig can be any type Convert accepts
+// values of.
+func (t *T) Explain(orig reflect.Type) (*Report, error) {
+	if orig == nil {
+		return nil, errors.New("orig is nil")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	filtered, err := t.mapType(context.Background(), "", orig)
+	if errors.Is(err, errDrop) {
+		return &Report{Type: orig.String(), Dropped: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Type:     orig.String(),
+		Filtered: filtered.String(),
+	}
+	t.explainType(report, make(map[*typeInfo]bool),
+		make(map[reflect.Type]bool), "", orig)
+	return report, nil
+}
+
+// explainType adds reports for all structure types reachable from orig at
+// the specified path to report. seen keeps track of filtered structure types
+// already reported, and active of named types of other kinds currently being
+// explained, which can be recursive, too.
+func (t *T) explainType(
+	report *Report, seen map[*typeInfo]bool, active map[reflect.Type]bool,
+	path string, orig reflect.Type,
+) {
+	if t.typeAction(orig) != ActionKeep {
+		return
+	}
+	if isNamedContainer(orig) {
+		if active[orig] {
+			return
+		}
+		active[orig] = true
+		defer delete(active, orig)
+	}
+	switch orig.Kind() {
+	case reflect.Array, reflect.Ptr, reflect.Slice:
+		t.explainType(report, seen, active, path, orig.Elem())
+	case reflect.Map:
+		t.explainType(report, seen, active, path, orig.Key())
+		t.explainType(report, seen, active, path, orig.Elem())
+	case reflect.Struct:
+		// Where recursion has been cut, a structure type depending on the
+		// path is filtered for that path only once a value is converted.
+		info, specific := t.cachedType("", path, orig)
+		if info == nil || seen[info] {
+			return
+		}
+		seen[info] = true
+		structReport := StructReport{
+			Type:   orig.String(),
+			Fields: make([]FieldReport, 0, len(info.fields)),
+		}
+		if specific {
+			structReport.Path = path
+		}
+		for i := range info.fields {
+			fi := &info.fields[i]
+			fieldReport := FieldReport{
+				Name:       fi.orig.Name,
+				Type:       fi.orig.Type.String(),
+				Kept:       fi.field.keep,
+				DecidedBy:  -1,
+				TagBefore:  string(fi.orig.Tag),
+				TagAfter:   string(fi.field.Tag),
+				RetaggedBy: fi.field.retaggedBy,
+				Recursive:  fi.cut,
+				NonData:    fi.nonData,
+				Sensitive:  fi.sensitive,
+				Redacted:   fi.field.keep && fi.field.redact,
+			}
+			if fi.field.decided {
+				fieldReport.DecidedBy = fi.field.decidedBy
+			}
+			if fieldReport.Kept {
+				fieldReport.FilteredType = fi.filtered.Type.String()
+			}
+			structReport.Fields = append(structReport.Fields, fieldReport)
+		}
+		report.Structs = append(report.Structs, structReport)
+		for i := range info.fields {
+			fi := &info.fields[i]
+			if fi.field.keep && !fi.field.redact {
+				t.explainType(report, seen, active, fi.field.path, fi.orig.Type)
+			}
+		}
+	}
+}
+
+// String renders the report as human readable text.
+func (r *Report) String() string {
+	var sb strings.Builder
+	if r.Dropped {
+		fmt.Fprintf(&sb, "%s => dropped\n", r.Type)
+	} else {
+		fmt.Fprintf(&sb, "%s => %s\n", r.Type, r.Filtered)
+	}
+	for _, s := range r.Structs {
+		if s.Path != "" {
+			fmt.Fprintf(&sb, "struct %s at %s:\n", s.Type, s.Path)
+		} else {
+			fmt.Fprintf(&sb, "struct %s:\n", s.Type)
+		}
+		for _, f := range s.Fields {
+			if f.Kept {
+				fmt.Fprintf(&sb, "\tkeep   %s %s => %s", f.Name, f.Type,
+					f.FilteredType)
+			} else {
+				fmt.Fprintf(&sb, "\tremove %s %s", f.Name, f.Type)
+			}
+			if f.DecidedBy >= 0 {
+				fmt.Fprintf(&sb, " (filter[%d])", f.DecidedBy)
+			} else {
+				sb.WriteString(" (default)")
+			}
+			if f.RetaggedBy >= 0 {
+				fmt.Fprintf(&sb, ", tag %q => %q (filter[%d])",
+					f.TagBefore, f.TagAfter, f.RetaggedBy)
+			}
+			if f.Recursive {
+				sb.WriteString(", recursion cut")
+			}
+			if f.NonData {
+				sb.WriteString(", non-data kind")
+			}
+			if f.Sensitive {
+				sb.WriteString(", sensitive type")
+			}
+			if f.Redacted {
+				sb.WriteString(", redacted")
+			}
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestExplain tests the Explain method.
+func TestExplain(t *testing.T) {
+	filter := New(
+		RemoveFieldFilter(regexp.MustCompile("^Remove.*$")),
+		InsertTagFilter(regexp.MustCompile("^TagMe$"), `test:"inserted"`),
+	)
+	type explained struct {
+		Keep1   int
+		Remove1 int
+		TagMe   string
+		Nested  []*NestedStruct
+		Rec     RecursiveStruct
+	}
+	report, err := filter.Explain(reflect.TypeOf(&explained{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	structs := make(map[string]StructReport)
+	for _, s := range report.Structs {
+		structs[s.Type] = s
+	}
+	if len(structs) != 4 {
+		t.Errorf("Expected 4 struct reports, got %d", len(structs))
+	}
+	top, ok := structs[reflect.TypeOf(explained{}).String()]
+	if !ok {
+		t.Fatal("Missing report for top level struct")
+	}
+	fields := make(map[string]FieldReport)
+	for _, f := range top.Fields {
+		fields[f.Name] = f
+	}
+	if f := fields["Keep1"]; !f.Kept || f.DecidedBy != -1 || f.RetaggedBy != -1 {
+		t.Errorf("Unexpected report for Keep1: %+v", f)
+	}
+	if f := fields["Remove1"]; f.Kept || f.DecidedBy != 0 ||
+		f.FilteredType != "" {
+		t.Errorf("Unexpected report for Remove1: %+v", f)
+	}
+	if f := fields["TagMe"]; f.RetaggedBy != 1 || f.TagBefore != "" ||
+		f.TagAfter != `test:"inserted"` {
+		t.Errorf("Unexpected report for TagMe: %+v", f)
+	}
+	rec := structs[reflect.TypeOf(RecursiveStruct{}).String()]
+	for _, f := range rec.Fields {
+		if !f.Recursive {
+			t.Errorf("Expected recursive field %s to be flagged", f.Name)
+		}
+	}
+	if !strings.Contains(report.String(), "remove Remove1 int (filter[0])") {
+		t.Errorf("Unexpected text report:\n%s", report)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("Unable to marshal report: %s", err)
+	}
+}
+
+// TestExplainError tests that Explain reports filter errors.
+func TestExplainError(t *testing.T) {
+	filter := New(errorFilter)
+	if _, err := filter.Explain(nil); err == nil {
+		t.Error("Expected error on nil type")
+	}
+	if _, err := filter.Explain(reflect.TypeOf(SimpleStruct{})); err == nil {
+		t.Error("Expected error with error filter")
+	}
+}
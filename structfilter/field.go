@@ -1,6 +1,7 @@
 package structfilter
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -10,11 +11,51 @@ type Field struct {
 	// cannot be changed.
 	name string
 
+	// path is the path of the field, see Path.
+	path string
+
+	// pathUsed indicates that a filter has called Path.
+	pathUsed bool
+
 	// tag is the tag of the new struct field.
 	Tag reflect.StructTag
 
 	// keep indicates whether the field should be kept.
 	keep bool
+
+	// redact indicates whether the field value should be redacted.
+	redact bool
+
+	// decided indicates whether a filter has explicitly called Keep or Remove.
+	decided bool
+
+	// stopped indicates that a filter has called Stop.
+	stopped bool
+
+	// filterIndex is the index of the filter function currently running.
+	filterIndex int
+
+	// decidedBy is the index of the filter function which last called Keep or
+	// Remove. Only meaningful if decided is true.
+	decidedBy int
+
+	// retaggedBy is the index of the filter function which last changed Tag,
+	// or -1 if Tag was never changed.
+	retaggedBy int
+
+	// ctx is the context of the conversion which caused the field to be
+	// filtered.
+	ctx context.Context
+
+	// profile is the profile the field is filtered for.
+	profile string
+
+	// typ is the type of the original field, or nil outside of structures.
+	typ reflect.Type
+
+	// structType is the original structure type containing the field, or nil
+	// outside of structures.
+	structType reflect.Type
 }
 
 // Name returns the name of this field.
@@ -22,38 +63,130 @@ func (f *Field) Name() string {
 	return f.name
 }
 
+// Path returns the path of this field: the names of the fields leading to it
+// from the type passed to T.Convert, separated by dots, e. g., "User.Email".
+// Array, slice, map, and pointer types do not add to the path. Structure
+// types reached through an interface value start a new path.
+//
+// Filtered types are normally cached per structure type, so filter functions
+// run only once per structure type. If a filter function calls Path, the
+// filtered type is cached per path instead, and filter functions run once
+// for each path the structure type is reached through. The same goes for all
+// structure types containing such a structure type.
+func (f *Field) Path() string {
+	f.pathUsed = true
+	return f.path
+}
+
+// Context returns the context of the conversion which caused this field to be
+// filtered, see T.ConvertContext. If the field is filtered outside of
+// T.ConvertContext, Context returns a background context. Context is only
+// valid while the filter function runs; it is not retained in the type cache.
+//
+// Filter decisions must not depend on request data carried by the context.
+// Filtered types are cached per profile, so filter functions run only once
+// per structure type and profile, with the context of the first conversion
+// involving that type, and their decisions apply to all later conversions
+// for the same profile. For per-request policy, use a profile, see
+// WithProfile, to select among a fixed set of views, or decide per value
+// with a value hook, see ValueView.Context, or a string function, see
+// StringFunc, both of which see the context of every conversion.
+func (f *Field) Context() context.Context {
+	return f.ctx
+}
+
+// Profile returns the profile this field is filtered for, see WithProfile.
+// Filter functions can use the profile to make different decisions for
+// different audiences, e. g., to show a field to administrators only.
+func (f *Field) Profile() string {
+	return f.profile
+}
+
+// Type returns the type of the original field. Type returns nil for object
+// members filtered by T.FilterJSON.
+func (f *Field) Type() reflect.Type {
+	return f.typ
+}
+
+// StructType returns the original structure type containing this field.
+// StructType returns nil for object members filtered by T.FilterJSON.
+func (f *Field) StructType() reflect.Type {
+	return f.structType
+}
+
+// Stop indicates that no further filter functions should be called for this
+// field. The decisions made so far stand. Stop does not count as a decision
+// with the default DefaultError, see T.SetDefault.
+func (f *Field) Stop() {
+	f.stopped = true
+}
+
 // Remove indicates that this field should not be part of the
 // filtered structure. A later filter might cause the field to be included
 // after all by calling Keep.
 func (f *Field) Remove() {
 	f.keep = false
+	f.redact = false
+	f.decided = true
+	f.decidedBy = f.filterIndex
 }
 
 // Keep indicates that this field should be part of the filtered structure.
-// This is the default. However, calling Keep explicitly may be necessary to
+// This is the default unless the default has been changed with
+// T.SetDefault. However, calling Keep explicitly may be necessary to
 // countermand a Remove call by an earlier filter. A later filter might cause
 // the field to be expluded after all by calling Remove again.
 func (f *Field) Keep() {
 	f.keep = true
+	f.redact = false
+	f.decided = true
+	f.decidedBy = f.filterIndex
+}
+
+// Redact indicates that this field should be part of the filtered structure,
+// but with its value replaced by the placeholder Redacted. The type of a
+// redacted field is string in the filtered structure. A later filter might
+// countermand Redact by calling Keep or Remove.
+func (f *Field) Redact() {
+	f.keep = true
+	f.redact = true
+	f.decided = true
+	f.decidedBy = f.filterIndex
+}
+
+// joinPath returns the path of the field with the specified name within a
+// structure at path prefix.
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
 }
 
 // newField creates a new struct field based on the original field and field.
+// For info on ctx, see T.mapType().
+// The second return value reports whether recursion had to be cut somewhere in
+// the field type.
 func (t *T) newField(
-	orig *reflect.StructField, field *Field,
-) (reflect.StructField, error) {
+	ctx context.Context, orig *reflect.StructField, field *Field,
+) (reflect.StructField, bool, error) {
 	result := reflect.StructField{
 		Name:      field.name,
 		Tag:       field.Tag,
 		Anonymous: orig.Anonymous,
 	}
-	mappedType, err := t.mapType(orig.Type)
+	if field.redact {
+		result.Type = stringType
+		return result, false, nil
+	}
+	mappedType, err := t.mapType(ctx, field.path, orig.Type)
 	if err != nil {
-		return reflect.StructField{}, err
+		return reflect.StructField{}, false, err
 	}
 	if mappedType == nil {
-		result.Type = interfaceType
-	} else {
-		result.Type = mappedType
+		result.Type = t.cutType()
+		return result, true, nil
 	}
-	return result, nil
+	result.Type = mappedType
+	return result, containsCut(mappedType), nil
 }
@@ -1,6 +1,7 @@
 package structfilter
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -25,3 +26,252 @@ func TestRemoveKeep(t *testing.T) {
 		t.Error("Expected all fields to be kept")
 	}
 }
+
+// PathInner is a structure type for testing field paths.
+type PathInner struct {
+	Secret string
+	Public string
+}
+
+// PathOuter is a structure type for testing field paths.
+type PathOuter struct {
+	Inner  PathInner
+	Items  []*PathInner
+	Secret int
+}
+
+// TestFieldPath tests the paths reported to filter functions.
+func TestFieldPath(t *testing.T) {
+	var paths []string
+	filter := New(func(f *Field) error {
+		paths = append(paths, f.Path())
+		return nil
+	})
+	if _, err := filter.Convert(PathOuter{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Inner", "Inner.Secret", "Inner.Public", "Items", "Items.Secret",
+		"Items.Public", "Secret",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+}
+
+// TestRedact tests redacting fields by path.
+func TestRedact(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.Path() == "Inner.Secret" || f.Path() == "Secret" {
+			f.Redact()
+		}
+		return nil
+	})
+	filtered, err := filter.Convert(PathOuter{
+		Inner:  PathInner{Secret: "s3cret", Public: "hello"},
+		Items:  []*PathInner{{Secret: "other"}},
+		Secret: 42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	if s := value.FieldByName("Secret").Interface(); s != Redacted {
+		t.Errorf("Expected redacted Secret, got %v", s)
+	}
+	inner := value.FieldByName("Inner")
+	if s := inner.FieldByName("Secret").Interface(); s != Redacted {
+		t.Errorf("Expected redacted Inner.Secret, got %v", s)
+	}
+	if s := inner.FieldByName("Public").Interface(); s != "hello" {
+		t.Errorf("Expected Inner.Public to be kept, got %v", s)
+	}
+	// Same type, different path: filtered types are cached by path.
+	item := value.FieldByName("Items").Index(0).Elem()
+	if s := item.FieldByName("Secret").Interface(); s != "other" {
+		t.Errorf("Expected Items[0].Secret to be kept, got %v", s)
+	}
+}
+
+// PathCredentials is a structure type reached through different paths.
+type PathCredentials struct {
+	User     string
+	Password string
+}
+
+// PathUser is a structure type containing PathCredentials.
+type PathUser struct {
+	Credentials PathCredentials
+}
+
+// PathOther is another structure type containing PathCredentials under a
+// different name.
+type PathOther struct {
+	Creds PathCredentials
+}
+
+// removePathFilter returns a filter function removing the field with the
+// specified path.
+func removePathFilter(path string) Func {
+	return func(f *Field) error {
+		if f.Path() == path {
+			f.Remove()
+		}
+		return nil
+	}
+}
+
+// TestFieldPathOrder tests that decisions based on the path do not depend on
+// the order in which types are converted.
+func TestFieldPathOrder(t *testing.T) {
+	creds := PathCredentials{User: "bob", Password: "hunter2"}
+	user, other := PathUser{Credentials: creds}, PathOther{Creds: creds}
+	for _, userFirst := range []bool{true, false} {
+		filter := New(removePathFilter("Credentials.Password"))
+		values := []interface{}{other, user}
+		if userFirst {
+			values[0], values[1] = user, other
+		}
+		for _, value := range values {
+			if _, err := filter.Convert(value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		filtered, err := filter.Convert(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credsValue := reflect.ValueOf(filtered).FieldByName("Credentials")
+		if credsValue.FieldByName("Password").IsValid() {
+			t.Errorf("Password leaked with user first: %t", userFirst)
+		}
+		filtered, err = filter.Convert(other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credsValue = reflect.ValueOf(filtered).FieldByName("Creds")
+		if !credsValue.FieldByName("Password").IsValid() {
+			t.Errorf("Password removed from other with user first: %t",
+				userFirst)
+		}
+	}
+}
+
+// PathNode is a recursive structure type for testing paths.
+type PathNode struct {
+	Secret string
+	Next   *PathNode
+}
+
+// TestFieldPathRecursive tests decisions based on the path for recursive
+// types, where recursion is cut.
+func TestFieldPathRecursive(t *testing.T) {
+	filter := New(removePathFilter("Next.Secret"))
+	orig := &PathNode{Secret: "a", Next: &PathNode{
+		Secret: "b", Next: &PathNode{Secret: "c"},
+	}}
+	for _, recursion := range []Recursion{
+		RecursionInterface, RecursionJSON, RecursionMap,
+	} {
+		filter.SetRecursion(recursion)
+		data, err := filter.EncodeJSON(orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		if err = json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal([]byte(
+			`{"Secret":"a","Next":{"Next":{"Secret":"c","Next":null}}}`,
+		), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Recursion %d: expected %v, got %s",
+				recursion, expected, data)
+		}
+	}
+	if _, err := filter.Explain(reflect.TypeOf(orig)); err != nil {
+		t.Error(err)
+	}
+	if _, err := filter.GoSource(reflect.TypeOf(orig)); err != nil {
+		t.Error(err)
+	}
+	if _, err := filter.JSONSchema(reflect.TypeOf(orig)); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestFieldPathCache tests that filtered types not depending on the path are
+// cached once per type.
+func TestFieldPathCache(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.Name() == "Password" {
+			f.Remove()
+		}
+		return nil
+	})
+	for _, value := range []interface{}{PathUser{}, PathOther{}} {
+		if _, err := filter.Convert(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cached := filter.Stats().Cached; cached != 3 {
+		t.Errorf("Expected 3 cached types, got %d", cached)
+	}
+}
+
+// PathShared is a structure type reaching a value via several paths.
+type PathShared struct {
+	X   *PathInner
+	Y   *PathInner
+	Any interface{}
+}
+
+// TestFieldPathAliasing tests converting a pointer reached via paths with
+// different filtered types.
+func TestFieldPathAliasing(t *testing.T) {
+	filter := New(removePathFilter("Y.Secret"))
+	inner := &PathInner{Secret: "hunter2", Public: "bob"}
+	filtered, err := filter.Convert(PathShared{X: inner, Y: inner, Any: inner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"X":{"Secret":"hunter2","Public":"bob"},` +
+		`"Y":{"Public":"bob"},"Any":{"Secret":"hunter2","Public":"bob"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	value := reflect.ValueOf(filtered)
+	if value.Field(0).Pointer() != value.Field(2).Elem().Pointer() {
+		t.Error("Expected aliasing to be preserved for equal filtered types")
+	}
+}
+
+// TestRedactUnnamed tests redacting string fields of unnamed structure types,
+// whose filtered type is identical to the original type.
+func TestRedactUnnamed(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.Name() == "Password" {
+			f.Redact()
+		}
+		return nil
+	})
+	orig := []struct{ Password string }{{"hunter2"}}
+	filtered, err := filter.Convert(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered).Index(0).Field(0)
+	if value.Interface() != Redacted {
+		t.Errorf("Expected redacted password, got %v", value)
+	}
+	if orig[0].Password != "hunter2" {
+		t.Error("Original value modified")
+	}
+}
@@ -1,9 +1,14 @@
 package structfilter
 
 import (
+	"container/list"
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Matcher is the interface implemented by types which match a certain subset
@@ -21,6 +26,25 @@ type Matcher interface {
 // error, it will be reported back to the original caller of a filter method.
 type Func func(*Field) error
 
+// Action describes what a filter does to a value it matches.
+type Action int
+
+const (
+	// ActionKeep keeps the value. This is the zero Action.
+	ActionKeep Action = iota
+
+	// ActionRemove removes the value.
+	ActionRemove
+
+	// ActionRedact replaces the value with the placeholder Redacted. Where
+	// the type of the value cannot hold a string, the value is replaced with
+	// its zero value instead.
+	ActionRedact
+)
+
+// Redacted is the placeholder for redacted values.
+const Redacted = "[REDACTED]"
+
 // RemoveFieldFilter returns a filter function for removing all struct fields
 // whose names match the specified matcher. If m is nil, RemoveFieldFilter
 // will not remove any fields.
@@ -38,6 +62,24 @@ func RemoveFieldFilter(m Matcher) Func {
 	}
 }
 
+// KeepFieldFilter returns a filter function for explicitly keeping all struct
+// fields whose names match the specified matcher. This is mostly useful with
+// a default of DefaultRemove or DefaultError, see T.SetDefault. If m is nil,
+// KeepFieldFilter will not keep any fields.
+func KeepFieldFilter(m Matcher) Func {
+	if m == nil {
+		return func(*Field) error {
+			return nil
+		}
+	}
+	return func(f *Field) error {
+		if m.MatchString(f.Name()) {
+			f.Keep()
+		}
+		return nil
+	}
+}
+
 // InsertTagFilter inserts the specified structure tag into the structure tags
 // of all fields whose name matches the specified matcher, provided the key in
 // the specified tag string is not present yet. The string tag must have the
@@ -71,29 +113,203 @@ func InsertTagFilter(m Matcher, tag string) Func {
 	}
 }
 
+// Default describes what happens to a struct field none of the filter
+// functions has explicitly kept or removed.
+type Default int
+
+const (
+	// DefaultKeep keeps fields unless a filter function removes them. This is
+	// the default.
+	DefaultKeep Default = iota
+
+	// DefaultRemove removes fields unless a filter function keeps them. With
+	// this default, a filter acts as an allowlist: fields added to a structure
+	// later on are left out until a filter function explicitly keeps them.
+	DefaultRemove
+
+	// DefaultError is like DefaultRemove, but filtering a structure type fails
+	// with ErrUndecided if no filter function has called either Keep or Remove
+	// on one of its fields.
+	DefaultError
+)
+
+// ErrUndecided is returned (possibly wrapped) if the default is DefaultError
+// and no filter function has decided whether to keep or remove a field.
+var ErrUndecided = errors.New("no filter decided on field")
+
 // T is the main structfilter type.
 //
-// The methods of T are unsafe for concurrent use.
+// The methods of T are safe for concurrent use. As conversions update the
+// type cache, they are serialised. Filter functions, type functions, key
+// functions, string functions, value hooks, and truncate functions run with
+// the structure filter locked, so they must not call its methods.
 type T struct {
+	// mutex protects the remaining fields. Exported methods acquire it,
+	// unexported methods expect it to be held.
+	mutex sync.RWMutex
+
 	// filter is the filter function this structfilter uses for filtering.
 	filter Func
 
-	// types maps original structure types to their filtered structure type.
-	types map[reflect.Type]reflect.Type
+	// dflt is the default for fields no filter has decided on.
+	dflt Default
+
+	// recursion is the strategy for representing recursive types.
+	recursion Recursion
+
+	// limits restricts the size of converted values.
+	limits Limits
+
+	// truncateFunc observes truncations by limits.
+	truncateFunc TruncateFunc
+
+	// nonData is the policy for types of non-data kinds.
+	nonData NonData
+
+	// hooks maps original structure types to their value hooks.
+	hooks map[reflect.Type][]ValueHook
+
+	// keyFilter decides on map entries by key.
+	keyFilter KeyFunc
+
+	// redacting indicates that some filtered structure type has redacted
+	// fields, see Field.Redact.
+	redacting bool
+
+	// sensitive maps types registered with RegisterSensitive to their
+	// actions.
+	sensitive map[reflect.Type]Action
+
+	// stringFilter transforms string values.
+	stringFilter StringFunc
+
+	// typeFilter decides on values by type.
+	typeFilter TypeFunc
+
+	// actions caches the actions for types not registered with
+	// RegisterSensitive, see T.typeAction.
+	actions map[reflect.Type]Action
+
+	// traits caches the traits of types, see typeTraits.
+	traits map[reflect.Type]typeTraits
+
+	// types maps profiles to maps from original structure types and paths to
+	// information about their filtered structure type, see WithProfile.
+	types map[string]map[typeKey]*typeInfo
+
+	// pending holds the original structure types currently being filtered,
+	// and the named types of other kinds currently being mapped, for each
+	// profile. Recursion is cut at these types.
+	pending map[rootKey]bool
+
+	// pathDependent indicates that the structure type currently being
+	// filtered depends on its path, see Field.Path.
+	pathDependent bool
+
+	// cacheLimit is the maximum number of root types in the type cache, or
+	// zero for no limit, see SetCacheLimit.
+	cacheLimit int
+
+	// roots lists the root types in the type cache as rootKeys, least
+	// recently used first.
+	roots *list.List
+
+	// rootElems maps the root types in the type cache to their elements in
+	// roots.
+	rootElems map[rootKey]*list.Element
+
+	// stats holds the cache statistics, see Stats.
+	stats CacheStats
+
+	// building is the nesting depth of running filterType calls.
+	building int
+}
+
+// typeKey identifies a filtered structure type in the type cache of a
+// profile.
+type typeKey struct {
+	// typ is the original structure type.
+	typ reflect.Type
+
+	// path is the path the original type has been filtered at, or anyPath if
+	// the filtered type does not depend on the path.
+	path string
+}
+
+// anyPath is the path of cached filtered types which do not depend on the
+// path. It cannot clash with a real path.
+const anyPath = "*"
+
+// typeInfo describes how a structure type has been filtered.
+type typeInfo struct {
+	// filtered is the filtered structure type.
+	filtered reflect.Type
+
+	// fields describes the exported fields of the original structure type.
+	fields []fieldInfo
+
+	// redacted holds the names of the redacted fields, or is nil if there are
+	// none.
+	redacted map[string]bool
+}
+
+// fieldInfo describes how a single structure field has been filtered.
+type fieldInfo struct {
+	// orig is the original structure field.
+	orig reflect.StructField
+
+	// field is the state of the field after all filters have run.
+	field Field
+
+	// filtered is the filtered structure field. Only valid if field.keep is
+	// true.
+	filtered reflect.StructField
+
+	// cut indicates that the filtered field type contains a recursion marker
+	// type because the original type is recursive.
+	cut bool
+
+	// nonData indicates that the field has been removed because its type
+	// involves a non-data kind, see NonDataDrop.
+	nonData bool
+
+	// sensitive indicates that the field has been removed because its type
+	// is removed by a type function or a sensitive type registration.
+	sensitive bool
 }
 
 // filterType returns the filtered type for the specified original type.
-// orig must not be in t.types yet.
-func (t *T) filterType(orig reflect.Type) (filtered reflect.Type, err error) {
-	t.types[orig] = nil // reserve our spot
+// orig must not be in the type cache for the profile of ctx and path yet. For
+// info on ctx and path, see T.mapType().
+func (t *T) filterType(
+	ctx context.Context, path string, orig reflect.Type,
+) (filtered reflect.Type, err error) {
+	profile := profileFromContext(ctx)
+	key := rootKey{profile: profile, typ: orig}
+	t.pending[key] = true
+	defer delete(t.pending, key)
+	outerDependent := t.pathDependent
+	t.pathDependent = false
+	defer func() {
+		t.pathDependent = t.pathDependent || outerDependent
+	}()
+	t.stats.Misses++
+	if t.building == 0 {
+		start := time.Now()
+		defer func() {
+			t.stats.BuildTime += time.Since(start)
+		}()
+	}
+	t.building++
+	defer func() {
+		t.building--
+	}()
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic attempting to create filtered type: %v", r)
 		}
-		if err != nil {
-			delete(t.types, orig)
-		}
 	}()
+	info := &typeInfo{}
 	filteredFields := make([]reflect.StructField, 0, orig.NumField())
 	for i := 0; i != orig.NumField(); i++ {
 		origField := orig.Field(i)
@@ -101,24 +317,69 @@ func (t *T) filterType(orig reflect.Type) (filtered reflect.Type, err error) {
 			continue
 		}
 		field := Field{
-			name: origField.Name,
-			Tag:  origField.Tag,
-			keep: true,
+			name:       origField.Name,
+			path:       joinPath(path, origField.Name),
+			Tag:        origField.Tag,
+			keep:       t.dflt == DefaultKeep,
+			retaggedBy: -1,
+			ctx:        ctx,
+			profile:    profile,
+			typ:        origField.Type,
+			structType: orig,
 		}
 		if err = t.filter(&field); err != nil {
 			return nil, fmt.Errorf("%s: %w", origField.Name, err)
 		}
-		if !field.keep {
-			continue
+		if field.Tag != origField.Tag && field.retaggedBy < 0 {
+			field.retaggedBy = 0 // single filter
 		}
-		newField, err := t.newField(&origField, &field)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", origField.Name, err)
+		if field.pathUsed {
+			t.pathDependent = true
+		}
+		if !field.decided && t.dflt == DefaultError {
+			return nil, fmt.Errorf("%s: %w", origField.Name, ErrUndecided)
 		}
-		filteredFields = append(filteredFields, newField)
+		// The cached field must not keep the context of the conversion
+		// alive, see Field.Context.
+		field.ctx = nil
+		fi := fieldInfo{
+			orig:  origField,
+			field: field,
+		}
+		if field.keep {
+			fi.filtered, fi.cut, err = t.newField(ctx, &origField, &field)
+			switch {
+			case errors.Is(err, errRemoved):
+				fi.field.keep = false
+				fi.sensitive = true
+				err = nil
+			case errors.Is(err, errDrop):
+				fi.field.keep = false
+				fi.nonData = true
+				err = nil
+			case err != nil:
+				return nil, fmt.Errorf("%s: %w", origField.Name, err)
+			default:
+				filteredFields = append(filteredFields, fi.filtered)
+			}
+			if fi.field.keep && field.redact {
+				if info.redacted == nil {
+					info.redacted = make(map[string]bool)
+				}
+				info.redacted[origField.Name] = true
+				t.redacting = true
+			}
+		}
+		info.fields = append(info.fields, fi)
 	}
 	filtered = reflect.StructOf(filteredFields)
-	t.types[orig] = filtered
+	t.stats.Created++
+	info.filtered = filtered
+	cacheKey := typeKey{typ: orig, path: anyPath}
+	if t.pathDependent {
+		cacheKey.path = path
+	}
+	t.profileTypes(profile)[cacheKey] = info
 	return
 }
 
@@ -126,12 +387,75 @@ func (t *T) filterType(orig reflect.Type) (filtered reflect.Type, err error) {
 // The filter functions are called in order for each structure field.
 func New(filters ...Func) *T {
 	return &T{
-		filter: combineFilters(filters),
-		types:  make(map[reflect.Type]reflect.Type),
+		filter:  combineFilters(filters),
+		types:   make(map[string]map[typeKey]*typeInfo),
+		pending: make(map[rootKey]bool),
+		actions: make(map[reflect.Type]Action),
+		traits:  make(map[reflect.Type]typeTraits),
+	}
+}
+
+// SetDefault sets what happens to struct fields none of the filter functions
+// has explicitly kept or removed. Changing the default discards all filtered
+// types created so far.
+func (t *T) SetDefault(dflt Default) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.dflt = dflt
+	t.resetTypes()
+}
+
+// resetTypes discards all filtered types created so far.
+func (t *T) resetTypes() {
+	t.types = make(map[string]map[typeKey]*typeInfo)
+	t.redacting = false
+	t.actions = make(map[reflect.Type]Action)
+	t.traits = make(map[reflect.Type]typeTraits)
+	t.roots = nil
+	t.rootElems = nil
+}
+
+// profileTypes returns the type cache for the specified profile.
+func (t *T) profileTypes(profile string) map[typeKey]*typeInfo {
+	types, ok := t.types[profile]
+	if !ok {
+		types = make(map[typeKey]*typeInfo)
+		t.types[profile] = types
+	}
+	return types
+}
+
+// cachedType returns the cached information about the filtered type for the
+// original structure type orig at the specified path for the specified
+// profile, or nil if there is none. The second return value reports whether
+// the information is specific to path.
+func (t *T) cachedType(
+	profile, path string, orig reflect.Type,
+) (*typeInfo, bool) {
+	types := t.types[profile]
+	if info, ok := types[typeKey{typ: orig, path: path}]; ok {
+		return info, true
 	}
+	return types[typeKey{typ: orig, path: anyPath}], false
+}
+
+// filteredInfo is like cachedType, but only returns information whose
+// filtered type is filtered.
+func (t *T) filteredInfo(
+	profile, path string, orig, filtered reflect.Type,
+) *typeInfo {
+	types := t.types[profile]
+	for _, p := range [...]string{path, anyPath} {
+		info := types[typeKey{typ: orig, path: p}]
+		if info != nil && info.filtered == filtered {
+			return info
+		}
+	}
+	return nil
 }
 
 // combineFilters combines multiple filters (or none) into a single filter.
+// The combined filter stops early if a filter calls Field.Stop.
 func combineFilters(filters []Func) Func {
 	switch len(filters) {
 	case 0:
@@ -143,9 +467,17 @@ func combineFilters(filters []Func) Func {
 	default:
 		return func(field *Field) error {
 			for i, filter := range filters {
+				tag := field.Tag
+				field.filterIndex = i
 				if err := filter(field); err != nil {
 					return fmt.Errorf("filter[%d]: %w", i, err)
 				}
+				if field.Tag != tag {
+					field.retaggedBy = i
+				}
+				if field.stopped {
+					break
+				}
 			}
 			return nil
 		}
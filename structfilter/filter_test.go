@@ -164,3 +164,74 @@ func TestInsertTagFilter(t *testing.T) {
 		t.Error("Already present tag was overwritten")
 	}
 }
+
+// TestKeepFieldFilter tests KeepFieldFilter with the DefaultRemove default.
+func TestKeepFieldFilter(t *testing.T) {
+	filter := New(KeepFieldFilter(regexp.MustCompile("^Keep.*$")))
+	filter.SetDefault(DefaultRemove)
+	filtered, err := filter.Convert(StructKeepRemove{Keep1: 1, Keep2: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	if value.NumField() != 2 {
+		t.Errorf("Expected 2 remaining fields, got %d", value.NumField())
+	}
+	if value.FieldByName("Remove1").IsValid() {
+		t.Error("Field that was not kept explicitly is still present")
+	}
+	if value.FieldByName("Keep2").Interface().(int) != 2 {
+		t.Error("Kept field has wrong value")
+	}
+	// Nil matcher
+	filter = New(KeepFieldFilter(nil))
+	filter.SetDefault(DefaultRemove)
+	filtered, err = filter.Convert(StructKeepRemove{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(filtered).NumField() != 0 {
+		t.Error("Expected no kept fields with nil matcher")
+	}
+}
+
+// TestDefaultError tests that undecided fields are reported with the
+// DefaultError default.
+func TestDefaultError(t *testing.T) {
+	filter := New(
+		KeepFieldFilter(regexp.MustCompile("^Keep.*$")),
+		RemoveFieldFilter(regexp.MustCompile("^Remove.*$")),
+	)
+	filter.SetDefault(DefaultError)
+	filtered, err := filter.Convert(StructKeepRemove{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(filtered).NumField() != 2 {
+		t.Error("Expected 2 remaining fields")
+	}
+	filter = New(KeepFieldFilter(regexp.MustCompile("^Keep.*$")))
+	filter.SetDefault(DefaultError)
+	if _, err = filter.Convert(StructKeepRemove{}); err == nil {
+		t.Error("Expected error with undecided fields")
+	} else if !errors.Is(err, ErrUndecided) {
+		t.Errorf("Expected undecided error, got: %s", err)
+	}
+}
+
+// TestSetDefaultResetsTypes tests that changing the default affects types
+// which have already been filtered.
+func TestSetDefaultResetsTypes(t *testing.T) {
+	filter := New()
+	if _, err := filter.Convert(StructKeepRemove{}); err != nil {
+		t.Fatal(err)
+	}
+	filter.SetDefault(DefaultRemove)
+	filtered, err := filter.Convert(StructKeepRemove{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(filtered).NumField() != 0 {
+		t.Error("Expected all fields to be removed after default change")
+	}
+}
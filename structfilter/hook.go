@@ -0,0 +1,136 @@
+package structfilter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// ValueHook is a function type for adjusting converted structure values based
+// on the original value. orig is the original structure value, and view
+// provides access to the filtered structure value. Whenever a ValueHook
+// returns a non-nil error, the conversion fails with that error.
+//
+// Unlike filter functions, which decide once per type, value hooks run for
+// every converted value, so they can base decisions on data, e. g., zero an
+// Email field if a Visibility field of the same structure says "private".
+type ValueHook func(orig reflect.Value, view *ValueView) error
+
+// ValueView provides access to a filtered structure value from within a
+// ValueHook.
+//
+// As all values of an original structure type are converted to the same
+// filtered type, a value hook cannot remove fields from individual values.
+// Instead, Omit sets a field to its zero value, which encoders honouring the
+// omitempty option leave out. Fields whose filtered type is an interface,
+// map, pointer, or slice become nil, which most other encoders render as
+// such.
+type ValueView struct {
+	// ctx is the context of the conversion.
+	ctx context.Context
+
+	// orig is the original structure value.
+	orig reflect.Value
+
+	// filtered is the filtered structure value. It is settable.
+	filtered reflect.Value
+}
+
+// Context returns the context of the conversion, see T.ConvertContext.
+func (v *ValueView) Context() context.Context {
+	return v.ctx
+}
+
+// Value returns the filtered structure value. The returned value is settable.
+func (v *ValueView) Value() reflect.Value {
+	return v.filtered
+}
+
+// Field returns the field with the specified name of the filtered structure
+// value. The returned value is settable. If the filtered structure has no
+// field with that name, e. g., because a filter function has removed it, the
+// returned value is invalid.
+func (v *ValueView) Field(name string) reflect.Value {
+	return v.filtered.FieldByName(name)
+}
+
+// Omit sets the field with the specified name to its zero value. Omit does
+// nothing if the filtered structure has no such field.
+func (v *ValueView) Omit(name string) {
+	field := v.Field(name)
+	if field.IsValid() {
+		field.Set(reflect.Zero(field.Type()))
+	}
+}
+
+// OmitEmpty omits all fields of the filtered structure value whose original
+// counterparts are empty in the sense of the omitempty option of the
+// encoding/json package.
+func (v *ValueView) OmitEmpty() {
+	for i := 0; i != v.filtered.NumField(); i++ {
+		name := v.filtered.Type().Field(i).Name
+		if isEmptyJSON(v.orig.FieldByName(name)) {
+			v.Omit(name)
+		}
+	}
+}
+
+// Redact replaces the value of the field with the specified name with
+// placeholder. The filtered field must have a string or an interface type.
+// Redact does nothing if the filtered structure has no such field.
+func (v *ValueView) Redact(name, placeholder string) error {
+	field := v.Field(name)
+	if !field.IsValid() {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(placeholder)
+	case reflect.Interface:
+		field.Set(reflect.ValueOf(placeholder))
+	default:
+		return fmt.Errorf("cannot redact field %s of type %s", name, field.Type())
+	}
+	return nil
+}
+
+// OmitEmptyHook is a value hook which calls OmitEmpty on every view.
+func OmitEmptyHook(orig reflect.Value, view *ValueView) error {
+	view.OmitEmpty()
+	return nil
+}
+
+// AddValueHook adds a value hook for values of the specified original
+// structure type. Value hooks run in the order they were added, after all
+// fields of a structure value have been converted.
+func (t *T) AddValueHook(orig reflect.Type, hook ValueHook) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.hooks == nil {
+		t.hooks = make(map[reflect.Type][]ValueHook)
+	}
+	t.hooks[orig] = append(t.hooks[orig], hook)
+	t.traits = make(map[reflect.Type]typeTraits)
+}
+
+// runValueHooks runs the value hooks for the original structure value
+// origValue on the filtered structure value filteredValue.
+func (t *T) runValueHooks(
+	state *convertState, origValue, filteredValue reflect.Value,
+) error {
+	hooks := t.hooks[origValue.Type()]
+	if len(hooks) == 0 {
+		return nil
+	}
+	view := &ValueView{
+		ctx:      state.ctx,
+		orig:     origValue,
+		filtered: filteredValue,
+	}
+	for i, hook := range hooks {
+		if err := hook(origValue, view); err != nil {
+			return fmt.Errorf("value hook[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
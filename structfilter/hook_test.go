@@ -0,0 +1,127 @@
+package structfilter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Profile is a structure type for testing value hooks.
+type Profile struct {
+	Name       string
+	Email      string
+	Phone      interface{}
+	Visibility string
+	Tags       []string
+	Age        int
+}
+
+// TestValueHook tests value dependent field omission.
+func TestValueHook(t *testing.T) {
+	filter := New()
+	filter.AddValueHook(reflect.TypeOf(Profile{}),
+		func(orig reflect.Value, view *ValueView) error {
+			if view.Context() == nil {
+				t.Error("Expected non-nil context in value hook")
+			}
+			if orig.FieldByName("Visibility").String() == "private" {
+				view.Omit("Email")
+				return view.Redact("Phone", "[REDACTED]")
+			}
+			return nil
+		})
+	filtered, err := filter.Convert([]Profile{
+		{Name: "Alice", Email: "alice@example.com", Visibility: "private"},
+		{Name: "Bob", Email: "bob@example.com", Phone: 12345},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	alice, bob := value.Index(0), value.Index(1)
+	if email := alice.FieldByName("Email").String(); email != "" {
+		t.Errorf("Expected private email to be omitted, got '%s'", email)
+	}
+	if phone := alice.FieldByName("Phone").Interface(); phone != "[REDACTED]" {
+		t.Errorf("Expected private phone to be redacted, got %v", phone)
+	}
+	if email := bob.FieldByName("Email").String(); email != "bob@example.com" {
+		t.Errorf("Expected public email to be kept, got '%s'", email)
+	}
+	if phone := bob.FieldByName("Phone").Interface(); phone != 12345 {
+		t.Errorf("Expected public phone to be kept, got %v", phone)
+	}
+}
+
+// TestValueHookErrors tests value hooks returning errors.
+func TestValueHookErrors(t *testing.T) {
+	filter := New()
+	filter.AddValueHook(reflect.TypeOf(Profile{}),
+		func(orig reflect.Value, view *ValueView) error {
+			return view.Redact("Age", "[REDACTED]")
+		})
+	if _, err := filter.Convert(Profile{}); err == nil {
+		t.Error("Expected error redacting int field")
+	}
+	filter = New()
+	filter.AddValueHook(reflect.TypeOf(Profile{}),
+		func(reflect.Value, *ValueView) error {
+			return errFilter
+		})
+	_, err := filter.ConvertContext(context.Background(), &Profile{})
+	if !errors.Is(err, errFilter) {
+		t.Errorf("Expected hook error, got: %v", err)
+	}
+}
+
+// TestOmitEmptyHook tests the OmitEmptyHook value hook.
+func TestOmitEmptyHook(t *testing.T) {
+	filter := New()
+	filter.AddValueHook(reflect.TypeOf(Profile{}), OmitEmptyHook)
+	filtered, err := filter.Convert(Profile{Name: "Alice", Tags: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	if !value.FieldByName("Tags").IsNil() {
+		t.Error("Expected empty slice to be omitted")
+	}
+	if value.FieldByName("Name").String() != "Alice" {
+		t.Error("Expected non-empty field to be kept")
+	}
+}
+
+// TestValueHookUnnamed tests value hooks for unnamed structure types, whose
+// filtered type is identical to the original type.
+func TestValueHookUnnamed(t *testing.T) {
+	type contact = struct {
+		Email      string
+		Visibility string
+	}
+	filter := New()
+	filter.AddValueHook(reflect.TypeOf(contact{}),
+		func(orig reflect.Value, view *ValueView) error {
+			if orig.FieldByName("Visibility").String() == "private" {
+				view.Omit("Email")
+			}
+			return nil
+		})
+	orig := contact{Email: "a@b.c", Visibility: "private"}
+	for _, value := range []interface{}{orig, []contact{orig}} {
+		filtered, err := filter.Convert(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := reflect.ValueOf(filtered)
+		if result.Kind() == reflect.Slice {
+			result = result.Index(0)
+		}
+		if email := result.FieldByName("Email").String(); email != "" {
+			t.Errorf("%T: expected email to be omitted, got '%s'", value, email)
+		}
+	}
+	if orig.Email != "a@b.c" {
+		t.Error("Original value modified")
+	}
+}
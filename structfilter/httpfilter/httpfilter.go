@@ -0,0 +1,278 @@
+// Package httpfilter filters HTTP response bodies with structure filters
+// from the structfilter package.
+//
+// A Handler picks a structure filter for each request and passes a wrapped
+// http.ResponseWriter to the next handler. Values rendered with Render are
+// converted with that filter and encoded as JSON. Bodies written directly
+// with a JSON content type are filtered with structfilter.T.FilterJSON.
+// Bodies written without a content type are held back until the next handler
+// returns, and filtered if they are valid JSON. If they are not, and a
+// structure filter has been picked, the response fails with status 500.
+// Bodies with other content types are passed through unchanged.
+//
+// Responses are filtered with the context of the request. If it carries a
+// profile, see structfilter.WithProfile, or a Handler picks one, a single
+// structure filter can produce different views for different roles.
+package httpfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TheCount/go-structfilter/structfilter"
+)
+
+// Handler is an http.Handler which filters the responses of another handler.
+type Handler struct {
+	// Next is the handler whose responses are filtered.
+	Next http.Handler
+
+	// Select picks the structure filter for a request, e. g., based on roles
+	// stored in the request context. If Select is nil or returns nil, all
+	// responses which require filtering fail with status 500.
+	Select func(r *http.Request) *structfilter.T
+
+	// Profile picks the profile for a request, see structfilter.WithProfile,
+	// e. g., based on roles stored in the request context. If Profile is nil,
+	// the profile carried by the request context, if any, is used.
+	Profile func(r *http.Request) string
+
+	// ErrorLog receives errors occurring while filtering. Clients only see a
+	// generic error response. If ErrorLog is nil, errors are logged with the
+	// standard logger of the log package.
+	ErrorLog *log.Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{
+		ResponseWriter: w,
+		handler:        h,
+		ctx:            r.Context(),
+		status:         http.StatusOK,
+	}
+	if h.Profile != nil {
+		rw.ctx = structfilter.WithProfile(rw.ctx, h.Profile(r))
+	}
+	if h.Select != nil {
+		rw.filter = h.Select(r)
+	}
+	h.Next.ServeHTTP(rw, r)
+	rw.finish()
+}
+
+// logf logs an error according to h.ErrorLog.
+func (h *Handler) logf(format string, args ...interface{}) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// Errors logged or returned by this package.
+var (
+	// errNoFilter is logged if a response requires filtering but no structure
+	// filter is available.
+	errNoFilter = errors.New("no structure filter for request")
+
+	// errLateRender is logged if Render is called after the header has been
+	// written.
+	errLateRender = errors.New("late call to Render after header was written")
+
+	// errRendered is returned when writing to a response writer after Render.
+	errRendered = errors.New("httpfilter: write after Render")
+
+	// errUndeclared is logged if a response body without content type is not
+	// valid JSON but a structure filter has been picked.
+	errUndeclared = errors.New("response body without content type is not JSON")
+)
+
+// Render writes v as the response body with the specified status code. v is
+// converted with the structure filter the Handler has picked for the request
+// and encoded as JSON for the profile of the request, see
+// structfilter.T.EncodeJSONContext. The content type is set to JSON.
+//
+// w must be the http.ResponseWriter a Handler has passed to the next
+// handler. If it is not, or if v cannot be converted or encoded, Render
+// responds with status 500 and a generic body instead. Render must be called
+// before anything else is written to w, and nothing can be written to w after
+// Render.
+func Render(w http.ResponseWriter, status int, v interface{}) {
+	rw, ok := w.(*responseWriter)
+	if !ok {
+		log.Print("httpfilter: Render called without Handler")
+		internalError(w)
+		return
+	}
+	if rw.wroteHeader {
+		rw.fail(errLateRender)
+		return
+	}
+	if rw.filter == nil {
+		rw.fail(errNoFilter)
+		return
+	}
+	data, err := rw.filter.EncodeJSONContext(rw.ctx, v)
+	if err != nil {
+		rw.fail(err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Header().Del("Content-Length")
+	rw.wroteHeader = true
+	rw.rendered = true
+	rw.ResponseWriter.WriteHeader(status)
+	if _, err = rw.ResponseWriter.Write(data); err != nil {
+		rw.handler.logf("httpfilter: write response: %v", err)
+	}
+}
+
+// responseWriter is the http.ResponseWriter a Handler passes to the next
+// handler.
+type responseWriter struct {
+	http.ResponseWriter
+
+	// handler is the handler which created this response writer.
+	handler *Handler
+
+	// filter is the structure filter for the request, or nil if there is
+	// none.
+	filter *structfilter.T
+
+	// ctx is the context responses are filtered with, carrying the profile
+	// for the request.
+	ctx context.Context
+
+	// status is the status code set by the next handler.
+	status int
+
+	// wroteHeader indicates that the header has been written, either to the
+	// underlying response writer or, when buffering, to status.
+	wroteHeader bool
+
+	// rendered indicates that the response body has been written by Render.
+	rendered bool
+
+	// buffering indicates that the response body is buffered until it is
+	// known whether it requires filtering.
+	buffering bool
+
+	// buf holds the buffered response body.
+	buf bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter. If the content type is JSON or
+// missing, the header is held back until the response body is complete.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = status
+	if contentType := rw.Header().Get("Content-Type"); contentType == "" ||
+		isJSON(contentType) {
+		rw.buffering = true
+		return
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if rw.rendered {
+		return 0, errRendered
+	}
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.buffering {
+		return rw.buf.Write(data)
+	}
+	return rw.ResponseWriter.Write(data)
+}
+
+// finish filters and writes a buffered response body, and writes the header
+// if the next handler has not done so. A buffered body without content type
+// is filtered as JSON if it is valid JSON.
+func (rw *responseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if !rw.buffering {
+		return
+	}
+	contentType := rw.Header().Get("Content-Type")
+	if contentType == "" && rw.buf.Len() != 0 {
+		if !json.Valid(rw.buf.Bytes()) {
+			if rw.filter != nil {
+				rw.fail(errUndeclared)
+				return
+			}
+			rw.writeBuffered(rw.buf.Bytes())
+			return
+		}
+		contentType = "application/json"
+		rw.Header().Set("Content-Type", contentType)
+	}
+	if !isJSON(contentType) {
+		rw.writeBuffered(rw.buf.Bytes())
+		return
+	}
+	if rw.filter == nil {
+		rw.fail(errNoFilter)
+		return
+	}
+	var out bytes.Buffer
+	err := rw.filter.FilterJSONContext(rw.ctx, &rw.buf, &out)
+	if err != nil {
+		rw.fail(err)
+		return
+	}
+	rw.Header().Del("Content-Length")
+	rw.writeBuffered(out.Bytes())
+}
+
+// writeBuffered writes the held back header and the specified body.
+func (rw *responseWriter) writeBuffered(body []byte) {
+	rw.ResponseWriter.WriteHeader(rw.status)
+	if _, err := rw.ResponseWriter.Write(body); err != nil {
+		rw.handler.logf("httpfilter: write response: %v", err)
+	}
+}
+
+// fail logs err and responds with a generic error, unless the header has
+// already been sent.
+func (rw *responseWriter) fail(err error) {
+	rw.handler.logf("httpfilter: %v", err)
+	if rw.wroteHeader && !rw.buffering {
+		return // too late
+	}
+	rw.wroteHeader = true
+	rw.buffering = false
+	rw.buf.Reset()
+	internalError(rw.ResponseWriter)
+}
+
+// internalError responds with status 500 and a generic body.
+func internalError(w http.ResponseWriter) {
+	w.Header().Del("Content-Length")
+	http.Error(w, http.StatusText(http.StatusInternalServerError),
+		http.StatusInternalServerError)
+}
+
+// isJSON reports whether the specified content type is a JSON media type.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
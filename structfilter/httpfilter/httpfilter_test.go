@@ -0,0 +1,330 @@
+package httpfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TheCount/go-structfilter/structfilter"
+)
+
+// User is a structure type for testing.
+type User struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Admin    bool   `json:"admin"`
+}
+
+// roleKey is the context key for the role of a request.
+type roleKey struct{}
+
+// newTestHandler returns a handler for testing, wrapping next. The structure
+// filter is picked by the role in the request context. Errors are logged to
+// errLog.
+func newTestHandler(next http.HandlerFunc, errLog *bytes.Buffer) *Handler {
+	admin := structfilter.New(
+		structfilter.RemoveFieldFilter(regexp.MustCompile(`^(?i)password$`)),
+	)
+	public := structfilter.New(
+		structfilter.RemoveFieldFilter(
+			regexp.MustCompile(`^(?i)(password|admin)$`)),
+	)
+	return &Handler{
+		Next: next,
+		Select: func(r *http.Request) *structfilter.T {
+			switch r.Context().Value(roleKey{}) {
+			case "admin":
+				return admin
+			case "public":
+				return public
+			default:
+				return nil
+			}
+		},
+		ErrorLog: log.New(errLog, "", 0),
+	}
+}
+
+// serve serves a request with the specified role with h and returns the
+// response.
+func serve(t *testing.T, h http.Handler, role string) *http.Response {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if role != "" {
+		r = r.WithContext(context.WithValue(r.Context(), roleKey{}, role))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+// body returns the body of resp.
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestRender tests rendering values with per-request filters.
+func TestRender(t *testing.T) {
+	var errLog bytes.Buffer
+	h := newTestHandler(func(w http.ResponseWriter, r *http.Request) {
+		Render(w, http.StatusCreated,
+			User{Name: "joe", Password: "pw", Admin: true})
+	}, &errLog)
+	for role, expected := range map[string]string{
+		"admin":  `{"name":"joe","admin":true}`,
+		"public": `{"name":"joe"}`,
+	} {
+		resp := serve(t, h, role)
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("%s: expected status 201, got %d", role, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !isJSON(ct) {
+			t.Errorf("%s: expected JSON content type, got %s", role, ct)
+		}
+		if b := body(t, resp); b != expected {
+			t.Errorf("%s: expected %s, got %s", role, expected, b)
+		}
+	}
+	if errLog.Len() != 0 {
+		t.Errorf("Unexpected errors: %s", errLog.String())
+	}
+}
+
+// TestFilterBody tests filtering JSON bodies written directly.
+func TestFilterBody(t *testing.T) {
+	var errLog bytes.Buffer
+	h := newTestHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "55")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"name":"joe",`))
+		w.Write([]byte(`"password":"pw","admin":true}`))
+	}, &errLog)
+	resp := serve(t, h, "public")
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", resp.StatusCode)
+	}
+	if b := body(t, resp); b != `{"name":"joe"}`+"\n" {
+		t.Errorf("Unexpected body %s", b)
+	}
+	// Other content types are passed through.
+	h.Next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`{"password":"pw"}`))
+	})
+	resp = serve(t, h, "public")
+	if b := body(t, resp); b != `{"password":"pw"}` {
+		t.Errorf("Unexpected body %s", b)
+	}
+	if errLog.Len() != 0 {
+		t.Errorf("Unexpected errors: %s", errLog.String())
+	}
+}
+
+// TestUndeclared tests response bodies written without a content type.
+func TestUndeclared(t *testing.T) {
+	var errLog bytes.Buffer
+	h := newTestHandler(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(User{Name: "bob", Password: "hunter2"})
+	}, &errLog)
+	resp := serve(t, h, "public")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !isJSON(ct) {
+		t.Errorf("Expected JSON content type, got %s", ct)
+	}
+	if b := body(t, resp); b != `{"name":"bob"}`+"\n" {
+		t.Errorf("Unexpected body %s", b)
+	}
+	if errLog.Len() != 0 {
+		t.Errorf("Unexpected errors: %s", errLog.String())
+	}
+	// Bodies which are not JSON fail if a filter has been picked.
+	h.Next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`password: hunter2`))
+	})
+	resp = serve(t, h, "public")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", resp.StatusCode)
+	}
+	if b := body(t, resp); strings.Contains(b, "hunter2") {
+		t.Errorf("Unexpected body %s", b)
+	}
+	if errLog.Len() == 0 {
+		t.Error("Expected errors to be logged")
+	}
+	// Without a filter, they are passed through.
+	resp = serve(t, h, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if b := body(t, resp); b != `password: hunter2` {
+		t.Errorf("Unexpected body %s", b)
+	}
+}
+
+// TestErrors tests that errors cause a generic status 500 response.
+func TestErrors(t *testing.T) {
+	var errLog bytes.Buffer
+	h := newTestHandler(func(w http.ResponseWriter, r *http.Request) {
+		Render(w, http.StatusOK, User{Password: "secret"})
+	}, &errLog)
+	expected := http.StatusText(http.StatusInternalServerError) + "\n"
+	// No filter for this request
+	resp := serve(t, h, "")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", resp.StatusCode)
+	}
+	if b := body(t, resp); b != expected {
+		t.Errorf("Unexpected body %s", b)
+	}
+	// Invalid JSON body
+	h.Next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"password":"secret",`))
+	})
+	resp = serve(t, h, "admin")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", resp.StatusCode)
+	}
+	if b := body(t, resp); b != expected {
+		t.Errorf("Unexpected body %s", b)
+	}
+	// Conversion error
+	h.Next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Render(w, http.StatusOK, map[string]interface{}{"c": make(chan int)})
+	})
+	resp = serve(t, h, "admin")
+	if b := body(t, resp); b != expected || strings.Contains(b, "chan") {
+		t.Errorf("Unexpected body %s", b)
+	}
+	if errLog.Len() == 0 {
+		t.Error("Expected errors to be logged")
+	}
+	// Render without Handler
+	w := httptest.NewRecorder()
+	Render(w, http.StatusOK, User{})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 without Handler, got %d", w.Code)
+	}
+}
+
+// TestConcurrent tests concurrent requests sharing structure filters.
+func TestConcurrent(t *testing.T) {
+	var errLog bytes.Buffer
+	h := newTestHandler(func(w http.ResponseWriter, r *http.Request) {
+		Render(w, http.StatusOK, []User{{Name: "a"}, {Name: "b"}})
+	}, &errLog)
+	var wg sync.WaitGroup
+	for i := 0; i != 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 16; j++ {
+				resp := serve(t, h, "public")
+				data, err := ioutil.ReadAll(resp.Body)
+				if err != nil || string(data) != `[{"name":"a"},{"name":"b"}]` {
+					t.Errorf("Unexpected body %s (%v)", data, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestConcurrentDirect tests a handler using the structure filter directly
+// while the middleware uses it as well.
+func TestConcurrentDirect(t *testing.T) {
+	filter := structfilter.New(
+		structfilter.RemoveFieldFilter(regexp.MustCompile(`^Password$`)),
+	)
+	h := &Handler{
+		Next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			users := []User{{Name: "a", Password: "secret"}}
+			if _, err := filter.Convert(users); err != nil {
+				t.Error(err)
+			}
+			Render(w, http.StatusOK, users)
+		}),
+		Select: func(r *http.Request) *structfilter.T {
+			return filter
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i != 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 16; j++ {
+				resp := serve(t, h, "")
+				data, err := ioutil.ReadAll(resp.Body)
+				if err != nil || string(data) != `[{"name":"a","admin":false}]` {
+					t.Errorf("Unexpected body %s (%v)", data, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestProfile tests filtering responses for the profile of a request with a
+// single structure filter.
+func TestProfile(t *testing.T) {
+	filter := structfilter.New(func(f *structfilter.Field) error {
+		switch {
+		case f.Name() == "Password" || f.Name() == "password":
+			f.Remove()
+		case (f.Name() == "Admin" || f.Name() == "admin") &&
+			f.Profile() != "admin":
+			f.Remove()
+		}
+		return nil
+	})
+	user := User{Name: "joe", Password: "hunter2", Admin: true}
+	for _, next := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			Render(w, http.StatusOK, user)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(user); err != nil {
+				t.Error(err)
+			}
+		},
+	} {
+		h := &Handler{
+			Next: next,
+			Select: func(r *http.Request) *structfilter.T {
+				return filter
+			},
+			Profile: func(r *http.Request) string {
+				role, _ := r.Context().Value(roleKey{}).(string)
+				return role
+			},
+		}
+		for role, expected := range map[string]string{
+			"admin":  `{"name":"joe","admin":true}`,
+			"public": `{"name":"joe"}`,
+		} {
+			resp := serve(t, h, role)
+			if actual := strings.TrimSpace(body(t, resp)); actual != expected {
+				t.Errorf("Expected body %s for role %s, got %s", expected, role,
+					actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package structfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// FilterJSON reads JSON values from r, filters them, and writes the filtered
+// values to w, each followed by a newline. The input is processed token by
+// token, without decoding it into Go values.
+//
+// The filter functions of t are called for every member of every JSON
+// object, as if the member were a structure field: Field.Name returns the
+// member name, Field.Path the member names leading to it, separated by dots,
+// and Field.Tag is initially empty. Arrays do not add to the path. A removed
+// member is left out, and the value of a redacted member is replaced with
+// Redacted. If the name in the json key of the resulting tag differs from the
+// member name, the member is renamed; a json key of "-" removes it. The
+// default applies as with structure fields, see T.SetDefault. Members kept by
+// the filter functions are subject to the key functions, see T.SetKeyFilters.
+//
+// Filter functions are called once per path and FilterJSON call. Numbers are
+// copied verbatim. Output is written compactly, without escaping HTML
+// characters. If FilterJSON fails, part of the output may have been written
+// already. As t is locked while FilterJSON runs, r and w should not block.
+func (t *T) FilterJSON(r io.Reader, w io.Writer) error {
+	return t.FilterJSONContext(context.Background(), r, w)
+}
+
+// FilterJSONContext is like FilterJSON, but passes ctx to the filter
+// functions, see Field.Context, and to the string functions. If ctx carries a
+// profile, see WithProfile, filter functions see it via Field.Profile.
+func (t *T) FilterJSONContext(
+	ctx context.Context, r io.Reader, w io.Writer,
+) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	s := &jsonFilterState{
+		ctx:       ctx,
+		decoder:   decoder,
+		writer:    bufio.NewWriter(w),
+		decisions: make(map[string]jsonDecision),
+	}
+	s.encoder = json.NewEncoder(&s.buf)
+	s.encoder.SetEscapeHTML(false)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = t.filterJSONValue(s, "", token); err != nil {
+			return err
+		}
+		if err = s.writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return s.writer.Flush()
+}
+
+// jsonFilterState holds the state of a single T.FilterJSON call.
+type jsonFilterState struct {
+	// ctx is the context of the filtering.
+	ctx context.Context
+
+	// decoder reads the input tokens.
+	decoder *json.Decoder
+
+	// writer receives the output.
+	writer *bufio.Writer
+
+	// encoder encodes strings into buf without escaping HTML characters.
+	encoder *json.Encoder
+
+	// buf is the output buffer of encoder.
+	buf bytes.Buffer
+
+	// decisions caches the decisions for object members by path.
+	decisions map[string]jsonDecision
+}
+
+// jsonDecision describes what happens to an object member.
+type jsonDecision struct {
+	// action is the action taken on the member.
+	action Action
+
+	// name is the name of the member in the output.
+	name string
+}
+
+// filterJSONValue filters the JSON value starting with token and writes it.
+// path is the path of the value.
+func (t *T) filterJSONValue(
+	s *jsonFilterState, path string, token json.Token,
+) error {
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			return t.filterJSONObject(s, path)
+		case '[':
+			return t.filterJSONArray(s, path)
+		default:
+			return fmt.Errorf("unexpected delimiter %s", token)
+		}
+	case string:
+		if t.stringFilter != nil {
+			filtered, err := t.stringFilter(s.ctx, token)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			token = filtered
+		}
+		return s.writeString(token)
+	case json.Number:
+		_, err := s.writer.WriteString(token.String())
+		return err
+	case bool:
+		if token {
+			_, err := s.writer.WriteString("true")
+			return err
+		}
+		_, err := s.writer.WriteString("false")
+		return err
+	case nil:
+		_, err := s.writer.WriteString("null")
+		return err
+	default:
+		return fmt.Errorf("unexpected token %v", token)
+	}
+}
+
+// filterJSONObject filters the members of a JSON object whose opening brace
+// has already been read, and writes the object. path is the path of the
+// object.
+func (t *T) filterJSONObject(s *jsonFilterState, path string) error {
+	if err := s.writer.WriteByte('{'); err != nil {
+		return err
+	}
+	first := true
+	for s.decoder.More() {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("unexpected object key %v", token)
+		}
+		memberPath := joinPath(path, key)
+		decision, err := t.decideJSONMember(s, memberPath, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", memberPath, err)
+		}
+		if decision.action == ActionRemove {
+			if err = s.skipValue(); err != nil {
+				return err
+			}
+			continue
+		}
+		if !first {
+			if err = s.writer.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err = s.writeString(decision.name); err != nil {
+			return err
+		}
+		if err = s.writer.WriteByte(':'); err != nil {
+			return err
+		}
+		if decision.action == ActionRedact {
+			if err = s.skipValue(); err != nil {
+				return err
+			}
+			if err = s.writeString(Redacted); err != nil {
+				return err
+			}
+			continue
+		}
+		if token, err = s.decoder.Token(); err != nil {
+			return err
+		}
+		if err = t.filterJSONValue(s, memberPath, token); err != nil {
+			return err
+		}
+	}
+	if _, err := s.decoder.Token(); err != nil { // closing brace
+		return err
+	}
+	return s.writer.WriteByte('}')
+}
+
+// filterJSONArray filters the elements of a JSON array whose opening bracket
+// has already been read, and writes the array. path is the path of the
+// array.
+func (t *T) filterJSONArray(s *jsonFilterState, path string) error {
+	if err := s.writer.WriteByte('['); err != nil {
+		return err
+	}
+	for i := 0; s.decoder.More(); i++ {
+		if i != 0 {
+			if err := s.writer.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err = t.filterJSONValue(s, path, token); err != nil {
+			return err
+		}
+	}
+	if _, err := s.decoder.Token(); err != nil { // closing bracket
+		return err
+	}
+	return s.writer.WriteByte(']')
+}
+
+// decideJSONMember decides what happens to the object member with the
+// specified name at the specified path.
+func (t *T) decideJSONMember(
+	s *jsonFilterState, path, name string,
+) (jsonDecision, error) {
+	if decision, ok := s.decisions[path]; ok {
+		return decision, nil
+	}
+	field := Field{
+		name:       name,
+		path:       path,
+		keep:       t.dflt == DefaultKeep,
+		retaggedBy: -1,
+		ctx:        s.ctx,
+		profile:    profileFromContext(s.ctx),
+	}
+	if err := t.filter(&field); err != nil {
+		return jsonDecision{}, err
+	}
+	if !field.decided && t.dflt == DefaultError {
+		return jsonDecision{}, ErrUndecided
+	}
+	decision := jsonDecision{action: ActionRemove}
+	if field.keep {
+		newName, _, ok := jsonField(reflect.StructField{
+			Name: name,
+			Tag:  field.Tag,
+		})
+		switch {
+		case !ok: // json:"-"
+		case field.redact:
+			decision = jsonDecision{action: ActionRedact, name: newName}
+		default:
+			decision = jsonDecision{name: newName}
+			if t.keyFilter != nil {
+				decision.action = t.keyFilter(name)
+			}
+		}
+	}
+	s.decisions[path] = decision
+	return decision, nil
+}
+
+// skipValue reads the next JSON value from the input and discards it.
+func (s *jsonFilterState) skipValue() error {
+	var raw json.RawMessage
+	return s.decoder.Decode(&raw)
+}
+
+// writeString writes str as a JSON string.
+func (s *jsonFilterState) writeString(str string) error {
+	s.buf.Reset()
+	if err := s.encoder.Encode(str); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(bytes.TrimSuffix(s.buf.Bytes(), []byte{'\n'}))
+	return err
+}
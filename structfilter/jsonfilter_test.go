@@ -0,0 +1,78 @@
+package structfilter
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestFilterJSON tests filtering JSON documents.
+func TestFilterJSON(t *testing.T) {
+	filter := New(
+		RemoveFieldFilter(regexp.MustCompile(`^password$`)),
+		func(f *Field) error {
+			switch f.Path() {
+			case "user.token":
+				f.Redact()
+			case "user.name":
+				f.Tag = `json:"login"`
+			case "user.internal":
+				f.Tag = `json:"-"`
+			}
+			return nil
+		},
+	)
+	input := `{"user": {"name": "joe", "token": {"a": [1, 2]}, "password": "x",
+		"internal": true, "roles": ["<admin>", null, 1.50e3]},
+		"items": [{"password": "y", "n": 1}, {"password": "z"}]}
+		[{"password": 1}]`
+	expected := `{"user":{"login":"joe","token":"[REDACTED]",` +
+		`"roles":["<admin>",null,1.50e3]},"items":[{"n":1},{}]}` + "\n" +
+		`[{}]` + "\n"
+	var out bytes.Buffer
+	if err := filter.FilterJSON(strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, out.String())
+	}
+}
+
+// TestFilterJSONKeyFilters tests that FilterJSON applies key functions.
+func TestFilterJSONKeyFilters(t *testing.T) {
+	filter := New()
+	filter.SetKeyFilters(
+		MapKeyFilter(regexp.MustCompile(`^secret$`), ActionRedact),
+		MapKeyFilter(regexp.MustCompile(`^drop$`), ActionRemove),
+	)
+	var out bytes.Buffer
+	if err := filter.FilterJSON(
+		strings.NewReader(`{"a":{"secret":1,"drop":2,"keep":3}}`), &out,
+	); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"a":{"secret":"[REDACTED]","keep":3}}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, out.String())
+	}
+}
+
+// TestFilterJSONErrors tests error reporting of FilterJSON.
+func TestFilterJSONErrors(t *testing.T) {
+	filter := New(KeepFieldFilter(regexp.MustCompile(`^a$`)))
+	filter.SetDefault(DefaultError)
+	var out bytes.Buffer
+	err := filter.FilterJSON(strings.NewReader(`{"a":{"b":1}}`), &out)
+	if !errors.Is(err, ErrUndecided) {
+		t.Errorf("Expected ErrUndecided, got %v", err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "a.b: ") {
+		t.Errorf("Expected error with path, got %s", err)
+	}
+	filter = New()
+	if err = filter.FilterJSON(strings.NewReader(`{"a":`), &out); err == nil {
+		t.Error("Expected error with truncated input")
+	}
+}
@@ -0,0 +1,83 @@
+package structfilter
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// jsonField returns the name under which the encoding/json package encodes
+// the specified structure field, along with the comma separated options from
+// its json tag. The last return value is false if encoding/json ignores the
+// field.
+func jsonField(field reflect.StructField) (name, opts string, ok bool) {
+	if field.PkgPath != "" {
+		return "", "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", "", false
+	}
+	name = tag
+	if idx := strings.Index(tag, ","); idx >= 0 {
+		name, opts = tag[:idx], tag[idx+1:]
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, opts, true
+}
+
+// validJSONName reports whether name is valid as a name in a json tag. How
+// the encoding/json package treats invalid names depends on the Go version.
+func validJSONName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// hasJSONOption reports whether the comma separated json tag options opts
+// contain the specified option.
+func hasJSONOption(opts, option string) bool {
+	for opts != "" {
+		var next string
+		if idx := strings.Index(opts, ","); idx >= 0 {
+			opts, next = opts[:idx], opts[idx+1:]
+		}
+		if opts == option {
+			return true
+		}
+		opts = next
+	}
+	return false
+}
+
+// isEmptyJSON reports whether v is empty in the sense of the omitempty option
+// of the encoding/json package.
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
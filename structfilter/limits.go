@@ -0,0 +1,233 @@
+package structfilter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// LimitAction describes what happens when a value exceeds a conversion limit.
+type LimitAction int
+
+const (
+	// LimitTruncate truncates values exceeding a limit. This is the default.
+	// Truncations can be detected with markers, see Limits, or with
+	// T.SetTruncateFunc.
+	LimitTruncate LimitAction = iota
+
+	// LimitFail causes the conversion to fail with a *LimitError.
+	LimitFail
+)
+
+// Limit is a single conversion limit.
+type Limit struct {
+	// Max is the maximum allowed. A Max of zero or less means no limit.
+	Max int
+
+	// Action is the action taken when Max is exceeded.
+	Action LimitAction
+}
+
+// Limits restricts the size of the values produced by Convert. The zero value
+// imposes no limits. Setting any limit disables the shallow copying of
+// values whose filtered type is identical to their original type, so that the
+// limits apply to these values as well.
+type Limits struct {
+	// Depth limits the nesting depth of values below the value passed to
+	// Convert. Each array or slice element, map key or value, structure field,
+	// and pointer target counts as one level. When truncating, values nested
+	// too deeply are left at their zero value.
+	Depth Limit
+
+	// Elements limits the number of elements of slices and the number of
+	// entries of maps. When truncating, only the first Max elements of a slice
+	// are converted. Which entries of a map are converted is unspecified.
+	// Arrays are not subject to this limit, as their length is part of their
+	// type, see Depth and Nodes instead.
+	Elements Limit
+
+	// StringLength limits the length of strings in bytes. When truncating,
+	// strings are cut at a rune boundary and TruncationMarker is appended.
+	StringLength Limit
+
+	// Nodes limits the total number of values visited during a single
+	// conversion. When truncating, values visited after the limit has been
+	// reached are left at their zero value.
+	Nodes Limit
+
+	// TruncationMarker is appended to truncated strings. The marker does not
+	// count towards StringLength.
+	TruncationMarker string
+
+	// ElementMarker, if not empty, marks slices and maps truncated by the
+	// Elements limit. It is appended as an extra element to truncated slices
+	// whose filtered element type is of string or interface kind. Truncated
+	// maps whose filtered key type is of string kind receive an extra entry
+	// with ElementMarker as key, unless such an entry exists already. The
+	// value of the extra entry is ElementMarker if the filtered value type is
+	// of string or interface kind, and the zero value otherwise. Other slices
+	// and maps cannot hold the marker; use T.SetTruncateFunc to detect their
+	// truncation.
+	ElementMarker string
+}
+
+// LimitError is returned (possibly wrapped) by Convert if a value exceeds a
+// limit whose action is LimitFail.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, i. e., the name of the
+	// corresponding field in Limits.
+	Limit string
+
+	// Max is the maximum which was exceeded.
+	Max int
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit, e.Max)
+}
+
+// SetLimits sets the limits for subsequent conversions.
+func (t *T) SetLimits(limits Limits) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.limits = limits
+}
+
+// TruncateFunc is a function type for observing truncations. ctx is the
+// context of the conversion, see T.ConvertContext, and err is the error the
+// conversion would have failed with if the action of the exceeded limit was
+// LimitFail.
+type TruncateFunc func(ctx context.Context, err *LimitError)
+
+// SetTruncateFunc sets the truncate function. During conversion, the truncate
+// function is called each time a limit whose action is LimitTruncate causes a
+// value to be truncated or skipped. This allows truncations to be detected
+// where markers cannot be used, e. g., to count them or to flag the result as
+// incomplete. A nil function, the default, disables the notifications.
+func (t *T) SetTruncateFunc(f TruncateFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.truncateFunc = f
+}
+
+// truncated notifies the truncate function, if any, of a truncation by the
+// limit with the specified name and maximum.
+func (t *T) truncated(state *convertState, name string, max int) {
+	if t.truncateFunc != nil {
+		t.truncateFunc(state.ctx, &LimitError{Limit: name, Max: max})
+	}
+}
+
+// contextCheckInterval is the number of values visited during a conversion
+// between checks of the conversion context.
+const contextCheckInterval = 256
+
+// checkNode accounts for a value at the specified depth being visited during
+// a conversion. It reports whether the value must be skipped because a limit
+// has been reached, or returns a *LimitError or the error of the conversion
+// context.
+func (t *T) checkNode(state *convertState, depth int) (bool, error) {
+	if limit := t.limits.Depth; limit.Max > 0 && depth > limit.Max {
+		if limit.Action == LimitFail {
+			return false, &LimitError{Limit: "Depth", Max: limit.Max}
+		}
+		t.truncated(state, "Depth", limit.Max)
+		return true, nil
+	}
+	state.nodes++
+	if state.nodes%contextCheckInterval == 0 {
+		if err := state.ctx.Err(); err != nil {
+			return false, err
+		}
+	}
+	if limit := t.limits.Nodes; limit.Max > 0 && state.nodes > limit.Max {
+		if limit.Action == LimitFail {
+			return false, &LimitError{Limit: "Nodes", Max: limit.Max}
+		}
+		t.truncated(state, "Nodes", limit.Max)
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkElements returns the number of elements to convert out of n slice
+// elements or map entries, or returns a *LimitError. For info on state, see
+// T.convertValue().
+func (t *T) checkElements(state *convertState, n int) (int, error) {
+	limit := t.limits.Elements
+	if limit.Max <= 0 || n <= limit.Max {
+		return n, nil
+	}
+	if limit.Action == LimitFail {
+		return 0, &LimitError{Limit: "Elements", Max: limit.Max}
+	}
+	t.truncated(state, "Elements", limit.Max)
+	return limit.Max, nil
+}
+
+// markElements adds the element marker to the filtered slice or map value
+// truncated by the Elements limit, if the marker is set and the value can
+// hold it, see Limits.ElementMarker.
+func (t *T) markElements(filteredValue reflect.Value) {
+	marker := t.limits.ElementMarker
+	if marker == "" {
+		return
+	}
+	typ := filteredValue.Type()
+	elem, ok := markerValue(typ.Elem(), marker)
+	if typ.Kind() == reflect.Slice {
+		if ok {
+			filteredValue.Set(reflect.Append(filteredValue, elem))
+		}
+		return
+	}
+	key, ok := markerValue(typ.Key(), marker)
+	if !ok || filteredValue.MapIndex(key).IsValid() {
+		return
+	}
+	filteredValue.SetMapIndex(key, elem)
+}
+
+// markerValue returns the marker as a value of the specified type, and
+// whether the type can hold the marker. If it cannot, the zero value of the
+// type is returned.
+func markerValue(typ reflect.Type, marker string) (reflect.Value, bool) {
+	switch typ.Kind() {
+	case reflect.String, reflect.Interface:
+		return reflect.ValueOf(marker).Convert(typ), true
+	default:
+		return reflect.Zero(typ), false
+	}
+}
+
+// convertString assigns the original string value to filteredValue, taking
+// the string functions and the string length limit into account. For info on
+// state, see T.convertValue().
+func (t *T) convertString(
+	state *convertState, origValue, filteredValue reflect.Value,
+) error {
+	limit := t.limits.StringLength
+	s := origValue.String()
+	if t.stringFilter != nil {
+		var err error
+		if s, err = t.stringFilter(state.ctx, s); err != nil {
+			return err
+		}
+	}
+	if limit.Max <= 0 || len(s) <= limit.Max {
+		filteredValue.SetString(s)
+		return nil
+	}
+	if limit.Action == LimitFail {
+		return &LimitError{Limit: "StringLength", Max: limit.Max}
+	}
+	t.truncated(state, "StringLength", limit.Max)
+	end := limit.Max
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	filteredValue.SetString(s[:end] + t.limits.TruncationMarker)
+	return nil
+}
@@ -0,0 +1,164 @@
+package structfilter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// LimitStruct is a structure type for testing conversion limits.
+type LimitStruct struct {
+	String string
+	Slice  []int
+	Map    map[string]int
+	Nested *LimitStruct
+}
+
+// testLimitValue returns a value for testing conversion limits.
+func testLimitValue() *LimitStruct {
+	return &LimitStruct{
+		String: "Hällo, world",
+		Slice:  []int{1, 2, 3, 4, 5},
+		Map:    map[string]int{"a": 1, "b": 2, "c": 3},
+		Nested: &LimitStruct{
+			Nested: &LimitStruct{
+				String: "deep",
+			},
+		},
+	}
+}
+
+// TestLimitsTruncate tests truncating conversion limits.
+func TestLimitsTruncate(t *testing.T) {
+	filter := New()
+	filter.SetLimits(Limits{
+		Depth:            Limit{Max: 4},
+		Elements:         Limit{Max: 2},
+		StringLength:     Limit{Max: 2},
+		TruncationMarker: "…",
+	})
+	filtered, err := filter.Convert(testLimitValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered).Elem()
+	if s := value.FieldByName("String").String(); s != "H…" {
+		t.Errorf("Expected truncated string 'H…', got '%s'", s)
+	}
+	if n := value.FieldByName("Slice").Len(); n != 2 {
+		t.Errorf("Expected 2 slice elements, got %d", n)
+	}
+	if n := value.FieldByName("Map").Len(); n != 2 {
+		t.Errorf("Expected 2 map entries, got %d", n)
+	}
+	// Nested has the Recursive type, which holds a pointer.
+	nested := value.FieldByName("Nested").Elem().Elem().FieldByName("Nested")
+	if nested.IsNil() {
+		t.Fatal("Expected nested value within depth limit")
+	}
+	if s := nested.Elem().Elem().FieldByName("String").String(); s != "" {
+		t.Errorf("Expected string beyond depth limit to be empty, got '%s'", s)
+	}
+}
+
+// TestLimitsNodes tests the node limit.
+func TestLimitsNodes(t *testing.T) {
+	filter := New()
+	filter.SetLimits(Limits{
+		Nodes: Limit{Max: 3},
+	})
+	filtered, err := filter.Convert([]int{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filtered, []int{1, 2, 0, 0}) {
+		t.Errorf("Unexpected result with node limit: %v", filtered)
+	}
+}
+
+// TestLimitsFail tests conversion limits which cause an error.
+func TestLimitsFail(t *testing.T) {
+	for _, limits := range []Limits{
+		{Depth: Limit{Max: 3, Action: LimitFail}},
+		{Elements: Limit{Max: 4, Action: LimitFail}},
+		{StringLength: Limit{Max: 4, Action: LimitFail}},
+		{Nodes: Limit{Max: 10, Action: LimitFail}},
+	} {
+		filter := New()
+		filter.SetLimits(limits)
+		_, err := filter.Convert(testLimitValue())
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected limit error with %+v, got: %v", limits, err)
+		}
+	}
+	filter := New()
+	filter.SetLimits(Limits{
+		Depth:        Limit{Max: 10, Action: LimitFail},
+		Elements:     Limit{Max: 10, Action: LimitFail},
+		StringLength: Limit{Max: 20, Action: LimitFail},
+		Nodes:        Limit{Max: 100, Action: LimitFail},
+	})
+	if _, err := filter.Convert(testLimitValue()); err != nil {
+		t.Errorf("Unexpected error within limits: %s", err)
+	}
+}
+
+// TestLimitsMarkers tests detecting truncations.
+func TestLimitsMarkers(t *testing.T) {
+	filter := New()
+	filter.SetLimits(Limits{
+		Elements:      Limit{Max: 2},
+		StringLength:  Limit{Max: 2},
+		ElementMarker: "…",
+	})
+	var truncations []string
+	filter.SetTruncateFunc(func(ctx context.Context, err *LimitError) {
+		truncations = append(truncations, err.Error())
+	})
+	filtered, err := filter.Convert(struct {
+		Strings []string
+		Values  []interface{}
+		Ints    []int
+		Map     map[string]int
+		Array   [3]int
+	}{
+		Strings: []string{"a", "b", "c"},
+		Values:  []interface{}{1, 2, 3},
+		Ints:    []int{1, 2, 3},
+		Map:     map[string]int{"a": 1, "b": 2, "c": 3},
+		Array:   [3]int{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	strings := value.FieldByName("Strings").Interface()
+	if !reflect.DeepEqual(strings, []string{"a", "b", "…"}) {
+		t.Errorf("Expected string slice with marker, got %v", strings)
+	}
+	values := value.FieldByName("Values").Interface()
+	if !reflect.DeepEqual(values, []interface{}{1, 2, "…"}) {
+		t.Errorf("Expected interface slice with marker, got %v", values)
+	}
+	if n := value.FieldByName("Ints").Len(); n != 2 {
+		t.Errorf("Expected 2 int slice elements, got %d", n)
+	}
+	m := value.FieldByName("Map").Interface().(map[string]int)
+	if _, ok := m["…"]; !ok || len(m) != 3 {
+		t.Errorf("Expected map with marker entry, got %v", m)
+	}
+	if n := value.FieldByName("Array").Len(); n != 3 {
+		t.Errorf("Expected array not to be truncated, got %d elements", n)
+	}
+	expected := []string{
+		"Elements limit of 2 exceeded",
+		"Elements limit of 2 exceeded",
+		"Elements limit of 2 exceeded",
+		"Elements limit of 2 exceeded",
+	}
+	if !reflect.DeepEqual(truncations, expected) {
+		t.Errorf("Expected truncations %v, got %v", expected, truncations)
+	}
+}
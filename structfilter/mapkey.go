@@ -0,0 +1,72 @@
+package structfilter
+
+import (
+	"reflect"
+)
+
+// KeyFunc is a function type for deciding on map entries by key during
+// conversion. Key functions apply to all maps with a key type of string kind,
+// including maps of type map[string]interface{} as obtained by decoding JSON
+// into an interface{}.
+type KeyFunc func(key string) Action
+
+// MapKeyFilter returns a key function which takes the specified action on all
+// map entries whose keys match the specified matcher. If m is nil, the
+// returned key function keeps all map entries.
+func MapKeyFilter(m Matcher, action Action) KeyFunc {
+	if m == nil {
+		return func(string) Action {
+			return ActionKeep
+		}
+	}
+	return func(key string) Action {
+		if m.MatchString(key) {
+			return action
+		}
+		return ActionKeep
+	}
+}
+
+// SetKeyFilters sets the key functions for map entries. For each map entry,
+// the first key function returning an action other than ActionKeep decides
+// what happens to the entry. If no key function does, the entry is kept. A
+// removed entry is left out of the converted map. The value of a redacted
+// entry is replaced with Redacted if the filtered map value type is of string
+// or interface kind, and with the zero value otherwise.
+func (t *T) SetKeyFilters(filters ...KeyFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(filters) == 0 {
+		t.keyFilter = nil
+		return
+	}
+	t.keyFilter = func(key string) Action {
+		for _, filter := range filters {
+			if action := filter(key); action != ActionKeep {
+				return action
+			}
+		}
+		return ActionKeep
+	}
+}
+
+// keyAction returns the action for the specified map key.
+func (t *T) keyAction(key reflect.Value) Action {
+	if t.keyFilter == nil || key.Kind() != reflect.String {
+		return ActionKeep
+	}
+	return t.keyFilter(key.String())
+}
+
+// redact sets value to the placeholder Redacted if possible, or to its zero
+// value otherwise.
+func redact(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(Redacted)
+	case reflect.Interface:
+		value.Set(reflect.ValueOf(Redacted))
+	default:
+		value.Set(reflect.Zero(value.Type()))
+	}
+}
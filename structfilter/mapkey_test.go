@@ -0,0 +1,107 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// Request is a structure type with a string keyed map for testing key
+// filters.
+type Request struct {
+	URL     string
+	Headers map[string]string
+	Counts  map[string]int
+}
+
+// TestMapKeyFilter tests key filters on typed maps.
+func TestMapKeyFilter(t *testing.T) {
+	filter := New()
+	filter.SetKeyFilters(
+		MapKeyFilter(nil, ActionRemove),
+		MapKeyFilter(regexp.MustCompile("^Authorization$"), ActionRemove),
+		MapKeyFilter(regexp.MustCompile("(?i)^(cookie|secret)$"), ActionRedact),
+	)
+	filtered, err := filter.Convert(Request{
+		URL: "https://example.com/",
+		Headers: map[string]string{
+			"Authorization": "Bearer secret",
+			"Cookie":        "session=secret",
+			"Accept":        "*/*",
+		},
+		Counts: map[string]int{"secret": 42, "public": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	headers := value.FieldByName("Headers").Interface().(map[string]string)
+	expected := map[string]string{"Cookie": Redacted, "Accept": "*/*"}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("Expected headers %v, got %v", expected, headers)
+	}
+	counts := value.FieldByName("Counts").Interface().(map[string]int)
+	if !reflect.DeepEqual(counts, map[string]int{"secret": 0, "public": 1}) {
+		t.Errorf("Expected redacted int to be zeroed, got %v", counts)
+	}
+}
+
+// TestMapKeyFilterJSON tests key filters on untyped data decoded from JSON.
+func TestMapKeyFilterJSON(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{
+		"user": {"name": "Alice", "password": "secret"},
+		"tokens": [{"token": "secret", "kind": "bearer"}],
+		"password": "secret"
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	filter := New()
+	filter.SetKeyFilters(
+		MapKeyFilter(regexp.MustCompile("^password$"), ActionRemove),
+		MapKeyFilter(regexp.MustCompile("^token$"), ActionRedact),
+	)
+	filtered, err := filter.Convert(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"tokens":[{"kind":"bearer","token":"[REDACTED]"}],` +
+		`"user":{"name":"Alice"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	if orig := doc.(map[string]interface{}); orig["password"] != "secret" {
+		t.Error("Original value has been modified")
+	}
+}
+
+// TestToValueInterfaceContainers tests that values in containers of
+// interfaces are filtered.
+func TestToValueInterfaceContainers(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile("^Remove.*$")))
+	orig := struct {
+		Slice []interface{}
+		Map   map[int]interface{}
+	}{
+		Slice: []interface{}{StructKeepRemove{Remove1: 1}},
+		Map:   map[int]interface{}{1: &StructKeepRemove{Remove2: 2}},
+	}
+	filtered, err := filter.Convert(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	elem := value.FieldByName("Slice").Index(0).Elem()
+	if elem.FieldByName("Remove1").IsValid() {
+		t.Error("Expected struct in slice of interfaces to be filtered")
+	}
+	elem = value.FieldByName("Map").MapIndex(reflect.ValueOf(1)).Elem().Elem()
+	if elem.FieldByName("Remove2").IsValid() {
+		t.Error("Expected struct in map of interfaces to be filtered")
+	}
+}
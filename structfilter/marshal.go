@@ -0,0 +1,399 @@
+package structfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// JSON returns a json.Marshaler which marshals v as filtered by t, see
+// T.EncodeJSON. This is useful for embedding filtered values in larger
+// structures passed to the encoding/json package.
+func JSON(t *T, v interface{}) json.Marshaler {
+	return jsonMarshaler{t: t, v: v}
+}
+
+// jsonMarshaler is the json.Marshaler returned by JSON.
+type jsonMarshaler struct {
+	// t is the structure filter.
+	t *T
+
+	// v is the value to be filtered.
+	v interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m jsonMarshaler) MarshalJSON() ([]byte, error) {
+	return m.t.EncodeJSON(m.v)
+}
+
+// EncodeJSON returns the JSON encoding of v as filtered by t. The result is
+// the same as that of json.Marshal on the value returned by t.Convert(v).
+// It is not called MarshalJSON, as methods of that name are expected to
+// implement json.Marshaler.
+//
+// Where possible, EncodeJSON writes the JSON encoding directly from v and the
+// cached filter decisions, without creating the converted value. Values
+// involving features the direct encoding does not support, e. g., recursion
+// cuts, limits, value hooks, key or string functions, a non-data policy
+// other than NonDataKeep, embedded fields, invalid names in json tags, or the
+// string option of json tags, are converted first.
+func (t *T) EncodeJSON(v interface{}) ([]byte, error) {
+	return t.EncodeJSONContext(context.Background(), v)
+}
+
+// EncodeJSONContext is like EncodeJSON, but converts v with ctx, see
+// T.ConvertContext. If ctx carries a profile, see WithProfile, v is encoded
+// for that profile.
+func (t *T) EncodeJSONContext(
+	ctx context.Context, v interface{},
+) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	data, err := t.encodeJSON(ctx, v)
+	if !errors.Is(err, errNotDirect) {
+		return data, err
+	}
+	converted, err := t.convertContext(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(converted)
+}
+
+// errNotDirect is used internally to signal that a value cannot be encoded
+// directly.
+var errNotDirect = errors.New("value cannot be encoded directly")
+
+// jsonMarshalerType and textMarshalerType are the reflect types of the
+// interfaces the encoding/json package honours.
+var (
+	jsonMarshalerType = reflect.TypeOf(new(json.Marshaler)).Elem()
+	textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+)
+
+// encodeJSON encodes v directly for the profile of ctx, or returns
+// errNotDirect.
+func (t *T) encodeJSON(ctx context.Context, v interface{}) ([]byte, error) {
+	if t.limits != (Limits{}) || len(t.hooks) != 0 || t.keyFilter != nil ||
+		t.stringFilter != nil || t.nonData != NonDataKeep {
+		return nil, errNotDirect
+	}
+	origValue := reflect.ValueOf(v)
+	if !origValue.IsValid() {
+		return []byte("null"), nil
+	}
+	t.useRoot(profileFromContext(ctx), origValue.Type())
+	filteredType, err := t.mapType(ctx, "", origValue.Type())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = t.encodeJSONValue(
+		ctx, &buf, "", origValue, filteredType,
+	); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJSONValue writes the JSON encoding of the converted counterpart of
+// origValue to buf. ctx is the context of the encoding, path is the path of
+// origValue, see Field.Path, and filteredType is the filtered type for the
+// type of origValue.
+func (t *T) encodeJSONValue(
+	ctx context.Context, buf *bytes.Buffer, path string,
+	origValue reflect.Value, filteredType reflect.Type,
+) error {
+	origType := origValue.Type()
+	if isCutType(filteredType) {
+		return errNotDirect
+	}
+	if t.typeAction(origType) == ActionRedact {
+		if isNilValue(origValue) {
+			buf.WriteString(`""`)
+			return nil
+		}
+		return writeJSONString(buf, Redacted)
+	}
+	if origType.Kind() == reflect.Interface {
+		if isNilJSONInterface(origValue) {
+			buf.WriteString("null")
+			return nil
+		}
+		elem := origValue.Elem()
+		elemType, err := t.mapType(ctx, "", elem.Type())
+		if errors.Is(err, errDrop) {
+			buf.WriteString("null")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return t.encodeJSONValue(ctx, buf, "", elem, elemType)
+	}
+	// Values copied shallowly by Convert can be left to encoding/json,
+	// unless their encoding depends on addressability.
+	if origType == filteredType && t.copyable(origType) {
+		if !origType.Implements(jsonMarshalerType) &&
+			!origType.Implements(textMarshalerType) &&
+			(reflect.PtrTo(origType).Implements(jsonMarshalerType) ||
+				reflect.PtrTo(origType).Implements(textMarshalerType)) {
+			return errNotDirect
+		}
+		data, err := json.Marshal(origValue.Interface())
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+	switch origType.Kind() {
+	case reflect.Array:
+		return t.encodeJSONElems(ctx, buf, path, origValue, filteredType)
+	case reflect.Map:
+		if origValue.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return t.encodeJSONMap(ctx, buf, path, origValue, filteredType)
+	case reflect.Ptr:
+		if origValue.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return t.encodeJSONValue(
+			ctx, buf, path, origValue.Elem(), filteredType.Elem(),
+		)
+	case reflect.Slice:
+		if origValue.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if filteredType.Elem().Kind() == reflect.Uint8 {
+			return errNotDirect // base64
+		}
+		return t.encodeJSONElems(ctx, buf, path, origValue, filteredType)
+	case reflect.Struct:
+		return t.encodeJSONStruct(ctx, buf, path, origValue, filteredType)
+	default:
+		return errNotDirect
+	}
+}
+
+// encodeJSONElems writes the elements of the original array or slice value
+// origValue as a JSON array to buf, leaving out elements removed by their
+// type. For info on ctx, path, and filteredType, see T.encodeJSONValue().
+func (t *T) encodeJSONElems(
+	ctx context.Context, buf *bytes.Buffer, path string,
+	origValue reflect.Value, filteredType reflect.Type,
+) error {
+	n := origValue.Len()
+	if filteredType.Kind() == reflect.Array {
+		n = filteredType.Len()
+	}
+	buf.WriteByte('[')
+	first := true
+	for i := 0; i != n; i++ {
+		if origValue.Kind() == reflect.Slice &&
+			t.isRemovedValue(origValue.Index(i)) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if err := t.encodeJSONValue(
+			ctx, buf, path, origValue.Index(i), filteredType.Elem(),
+		); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// encodeJSONMap writes the original non-nil map value origValue as a JSON
+// object to buf, with keys sorted like the encoding/json package does. For
+// info on ctx, path, and filteredType, see T.encodeJSONValue().
+func (t *T) encodeJSONMap(
+	ctx context.Context, buf *bytes.Buffer, path string,
+	origValue reflect.Value, filteredType reflect.Type,
+) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, origValue.Len())
+	iter := origValue.MapRange()
+	for iter.Next() {
+		if t.isRemovedValue(iter.Key()) || t.isRemovedValue(iter.Value()) {
+			continue
+		}
+		var key string
+		k := iter.Key()
+		if k.Kind() != reflect.String && k.Type().Implements(textMarshalerType) {
+			return errNotDirect
+		}
+		switch k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64, reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return errNotDirect
+		}
+		entries = append(entries, entry{key: key, value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	buf.WriteByte('{')
+	for i, e := range entries {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONString(buf, e.key); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := t.encodeJSONValue(
+			ctx, buf, path, e.value, filteredType.Elem(),
+		); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encodeJSONStruct writes the original structure value origValue as a JSON
+// object to buf, according to the filtered structure type. For info on ctx,
+// path, and filteredType, see T.encodeJSONValue().
+func (t *T) encodeJSONStruct(
+	ctx context.Context, buf *bytes.Buffer, path string,
+	origValue reflect.Value, filteredType reflect.Type,
+) error {
+	profile := profileFromContext(ctx)
+	info := t.filteredInfo(profile, path, origValue.Type(), filteredType)
+	if info == nil {
+		return errNotDirect
+	}
+	names := make(map[string]bool, len(info.fields))
+	buf.WriteByte('{')
+	first := true
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if !fi.field.keep {
+			continue
+		}
+		name, opts, ok := jsonField(fi.filtered)
+		if !ok {
+			continue
+		}
+		if fi.filtered.Anonymous || hasJSONOption(opts, "string") ||
+			!validJSONName(name) || names[name] {
+			return errNotDirect
+		}
+		names[name] = true
+		fieldValue := origValue.FieldByIndex(fi.orig.Index)
+		if !fi.field.redact && hasJSONOption(opts, "omitempty") &&
+			t.isEmptyConverted(ctx, fieldValue) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if err := writeJSONString(buf, name); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if fi.field.redact {
+			if err := writeJSONString(buf, Redacted); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.encodeJSONValue(
+			ctx, buf, fi.field.path, fieldValue, fi.filtered.Type,
+		); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// isEmptyConverted reports whether the converted counterpart of the original
+// value is empty in the sense of the omitempty option of the encoding/json
+// package. ctx is the context of the encoding.
+func (t *T) isEmptyConverted(
+	ctx context.Context, origValue reflect.Value,
+) bool {
+	if t.typeAction(origValue.Type()) == ActionRedact {
+		return isNilValue(origValue)
+	}
+	switch origValue.Kind() {
+	case reflect.Array:
+		_, err := t.mapType(ctx, "", origValue.Type().Elem())
+		return origValue.Len() == 0 || errors.Is(err, errRemoved)
+	case reflect.Map:
+		iter := origValue.MapRange()
+		for iter.Next() {
+			if !t.isRemovedValue(iter.Key()) && !t.isRemovedValue(iter.Value()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		for i := 0; i != origValue.Len(); i++ {
+			if !t.isRemovedValue(origValue.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return isEmptyJSON(origValue) || isNilJSONInterface(origValue)
+}
+
+// isNilJSONInterface reports whether value is an interface which Convert
+// converts to nil: a nil interface, or one holding a nil map, pointer, or
+// slice.
+func isNilJSONInterface(value reflect.Value) bool {
+	if value.Kind() != reflect.Interface {
+		return false
+	}
+	if value.IsNil() {
+		return true
+	}
+	switch elem := value.Elem(); elem.Kind() {
+	case reflect.Map, reflect.Ptr, reflect.Slice:
+		return elem.IsNil()
+	default:
+		return false
+	}
+}
+
+// writeJSONString writes s to buf as a JSON string, escaped like the
+// encoding/json package does.
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
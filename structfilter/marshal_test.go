@@ -0,0 +1,166 @@
+package structfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// MarshalInner is a structure type for testing EncodeJSON.
+type MarshalInner struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Token    []byte `json:"token,omitempty"`
+	Count    int    `json:",omitempty"`
+	Ignored  int    `json:"-"`
+	Dash     int    `json:"-,"`
+	private  int
+}
+
+// MarshalOuter is a structure type for testing EncodeJSON.
+type MarshalOuter struct {
+	Inner   MarshalInner
+	PInner  *MarshalInner `json:"pinner,omitempty"`
+	Inners  []MarshalInner
+	ByName  map[string]*MarshalInner
+	ByID    map[int]MarshalInner
+	Any     interface{} `json:"any,omitempty"`
+	AnyNil  interface{} `json:"anyNil,omitempty"`
+	Secret  string
+	When    time.Time
+	Big     *big.Int
+	Numbers [3]float64
+	Html    string
+	Anon    struct{ Password, Public string }
+}
+
+// MarshalIndirect is a structure type for testing EncodeJSON with features
+// the direct encoding does not support.
+type MarshalIndirect struct {
+	MarshalInner
+	Invalid int `json:"a\"b"`
+	Stringy int `json:",string"`
+}
+
+// marshalTestValues returns values for testing EncodeJSON.
+func marshalTestValues() []interface{} {
+	inner := MarshalInner{
+		Name:     "alice",
+		Password: "secret",
+		Token:    []byte{1, 2, 3},
+		Count:    0,
+		Ignored:  2,
+		Dash:     3,
+		private:  4,
+	}
+	outer := MarshalOuter{
+		Inner:   inner,
+		PInner:  &inner,
+		Inners:  []MarshalInner{inner, {}},
+		ByName:  map[string]*MarshalInner{"b": &inner, "a": nil, "<c>": {}},
+		ByID:    map[int]MarshalInner{10: inner, 9: {}},
+		Any:     []interface{}{inner, &inner, map[string]interface{}{"x": 1.5}},
+		AnyNil:  (*MarshalInner)(nil),
+		Secret:  "hidden",
+		When:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Big:     big.NewInt(12345),
+		Numbers: [3]float64{1, 0.000001, 1e21},
+		Html:    "<a href=\"x\">&</a>",
+	}
+	outer.Anon.Password = "anon"
+	outer.Anon.Public = "public"
+	return []interface{}{
+		nil,
+		42,
+		"string",
+		inner,
+		&inner,
+		[]MarshalInner{inner},
+		map[string]MarshalInner{"k": inner},
+		outer,
+		&outer,
+		MarshalOuter{},
+		[]interface{}{nil, inner, (*MarshalInner)(nil)},
+		struct{ Password string }{"anon"},
+		MarshalIndirect{MarshalInner: inner, Invalid: 1, Stringy: 2},
+	}
+}
+
+// TestEncodeJSONDifferential tests that EncodeJSON yields the same output as
+// json.Marshal on the converted value.
+func TestEncodeJSONDifferential(t *testing.T) {
+	filters := map[string]*T{
+		"nop":    New(),
+		"remove": New(RemoveFieldFilter(regexp.MustCompile(`Password|Secret`))),
+		"redact": New(func(f *Field) error {
+			if f.Name() == "Password" || f.Name() == "Secret" {
+				f.Redact()
+			}
+			return nil
+		}),
+		"retag": New(InsertTagFilter(
+			regexp.MustCompile(`.`), `json:"x,omitempty"`,
+		)),
+	}
+	keyFiltered := New()
+	keyFiltered.SetKeyFilters(MapKeyFilter(regexp.MustCompile(`^b$`), ActionRemove))
+	filters["keys"] = keyFiltered
+	for name, filter := range filters {
+		for i, v := range marshalTestValues() {
+			converted, err := filter.Convert(v)
+			if err != nil {
+				t.Fatalf("%s[%d]: convert: %s", name, i, err)
+			}
+			expected, expectedErr := json.Marshal(converted)
+			actual, err := filter.EncodeJSON(v)
+			if (err != nil) != (expectedErr != nil) {
+				t.Errorf("%s[%d]: expected error %v, got %v", name, i,
+					expectedErr, err)
+				continue
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("%s[%d]: expected\n%s\ngot\n%s", name, i, expected, actual)
+			}
+		}
+	}
+}
+
+// TestEncodeJSONDirect tests that EncodeJSON encodes supported values
+// directly.
+func TestEncodeJSONDirect(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile(`^Password$`)))
+	ctx := context.Background()
+	if _, err := filter.encodeJSON(ctx, &StructTag{}); err != nil {
+		t.Errorf("Expected direct encoding, got %s", err)
+	}
+	if _, err := filter.encodeJSON(ctx, MarshalOuter{}); err != nil {
+		t.Errorf("Expected direct encoding, got %s", err)
+	}
+	if _, err := filter.encodeJSON(ctx, MarshalIndirect{}); err != errNotDirect {
+		t.Errorf("Expected no direct encoding with invalid tag, got %v", err)
+	}
+	root := &TreeNode{Name: "root"}
+	root.Children = []*TreeNode{{Name: "child", Parent: root}}
+	if _, err := filter.encodeJSON(ctx, root); err != errNotDirect {
+		t.Errorf("Expected no direct encoding of recursive type, got %v", err)
+	}
+}
+
+// TestJSON tests the json.Marshaler returned by JSON.
+func TestJSON(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile(`^Remove`)))
+	data, err := json.Marshal(map[string]interface{}{
+		"user": JSON(filter, StructKeepRemove{Keep1: 1, Remove1: 2}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"user":{"Keep1":1,"Keep2":0}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
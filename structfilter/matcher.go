@@ -0,0 +1,264 @@
+package structfilter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Glob returns a matcher which matches strings against the specified shell
+// style pattern. In the pattern, '*' matches any sequence of characters, '?'
+// matches any single character, and '\' escapes the following character. All
+// other characters match themselves. For example, Glob("Password*") matches
+// "Password" and "PasswordHash", but not "OldPassword".
+func Glob(pattern string) Matcher {
+	return compileGlobs(false, pattern)
+}
+
+// Exact returns a matcher which matches the specified names exactly. The
+// names are kept in a set, so matching takes constant time regardless of the
+// number of names.
+func Exact(names ...string) Matcher {
+	set := make(exactMatcher, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// CaseInsensitive returns a matcher which matches strings against any of the
+// specified patterns, see Glob, ignoring letter case. Patterns without
+// wildcards are kept in a set, like with Exact.
+func CaseInsensitive(patterns ...string) Matcher {
+	return compileGlobs(true, patterns...)
+}
+
+// Any returns a matcher which matches strings matched by at least one of the
+// specified matchers. If no matchers are specified, the returned matcher
+// matches nothing.
+func Any(matchers ...Matcher) Matcher {
+	return anyMatcher(matchers)
+}
+
+// All returns a matcher which matches strings matched by all of the specified
+// matchers. If no matchers are specified, the returned matcher matches
+// everything.
+func All(matchers ...Matcher) Matcher {
+	return allMatcher(matchers)
+}
+
+// Not returns a matcher which matches strings not matched by m.
+func Not(m Matcher) Matcher {
+	return notMatcher{m: m}
+}
+
+// PathPattern returns a matcher for field paths, see Field.Path. The pattern
+// consists of segments separated by dots. A segment of "**" matches any
+// number of path segments, including none. Any other segment matches a
+// single path segment as in Glob. For example, "**.Credentials.*" matches
+// "Credentials.Password" and "User.Credentials.Token", but not
+// "User.Credentials".
+//
+// Use PathFilter to apply path patterns to fields.
+func PathPattern(pattern string) Matcher {
+	var segments []Matcher
+	for _, segment := range strings.Split(pattern, ".") {
+		if segment == "**" {
+			if len(segments) == 0 || segments[len(segments)-1] != nil {
+				segments = append(segments, nil)
+			}
+			continue
+		}
+		segments = append(segments, Glob(segment))
+	}
+	return pathMatcher(segments)
+}
+
+// PathFilter returns a filter function which takes the specified action on
+// all struct fields whose paths match the specified matcher, see Field.Path.
+// ActionKeep keeps, ActionRemove removes, and ActionRedact redacts matching
+// fields. If m is nil, PathFilter will not decide on any fields.
+func PathFilter(m Matcher, action Action) Func {
+	if m == nil {
+		return func(*Field) error {
+			return nil
+		}
+	}
+	return func(f *Field) error {
+		if !m.MatchString(f.Path()) {
+			return nil
+		}
+		switch action {
+		case ActionRemove:
+			f.Remove()
+		case ActionRedact:
+			f.Redact()
+		default:
+			f.Keep()
+		}
+		return nil
+	}
+}
+
+// exactMatcher is the Matcher returned by Exact.
+type exactMatcher map[string]struct{}
+
+// MatchString implements Matcher.MatchString.
+func (m exactMatcher) MatchString(s string) bool {
+	_, ok := m[s]
+	return ok
+}
+
+// globMatcher matches strings against glob patterns.
+type globMatcher struct {
+	// fold indicates that letter case is ignored. If fold is true, the keys
+	// in exact are in lower case.
+	fold bool
+
+	// exact holds the patterns without wildcards.
+	exact exactMatcher
+
+	// re matches the patterns with wildcards, or is nil if there are none.
+	re *regexp.Regexp
+}
+
+// compileGlobs compiles the specified glob patterns into a single matcher.
+func compileGlobs(fold bool, patterns ...string) *globMatcher {
+	m := &globMatcher{
+		fold:  fold,
+		exact: make(exactMatcher),
+	}
+	var exprs []string
+	for _, pattern := range patterns {
+		expr, literal, isLiteral := globToRegexp(pattern)
+		if !isLiteral {
+			exprs = append(exprs, expr)
+			continue
+		}
+		if fold {
+			literal = strings.ToLower(literal)
+		}
+		m.exact[literal] = struct{}{}
+	}
+	if len(exprs) != 0 {
+		prefix := "(?s)"
+		if fold {
+			prefix = "(?is)"
+		}
+		m.re = regexp.MustCompile(
+			prefix + `^(?:` + strings.Join(exprs, "|") + `)$`,
+		)
+	}
+	return m
+}
+
+// MatchString implements Matcher.MatchString.
+func (m *globMatcher) MatchString(s string) bool {
+	key := s
+	if m.fold {
+		key = strings.ToLower(s)
+	}
+	if m.exact.MatchString(key) {
+		return true
+	}
+	return m.re != nil && m.re.MatchString(s)
+}
+
+// globToRegexp translates the specified glob pattern into a regular
+// expression. If the pattern contains no wildcards, globToRegexp instead
+// returns the unescaped string the pattern matches, and isLiteral is true.
+func globToRegexp(pattern string) (expr, literal string, isLiteral bool) {
+	var re, lit strings.Builder
+	isLiteral = true
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			re.WriteString(".*")
+			isLiteral = false
+		case '?':
+			re.WriteString(".")
+			isLiteral = false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			fallthrough
+		default:
+			// Copy the run of ordinary characters starting here at once, so
+			// that multibyte characters stay intact.
+			j := i + 1
+			for j < len(pattern) && strings.IndexByte(`*?\`, pattern[j]) < 0 {
+				j++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i:j]))
+			lit.WriteString(pattern[i:j])
+			i = j - 1
+		}
+	}
+	return re.String(), lit.String(), isLiteral
+}
+
+// anyMatcher is the Matcher returned by Any.
+type anyMatcher []Matcher
+
+// MatchString implements Matcher.MatchString.
+func (m anyMatcher) MatchString(s string) bool {
+	for _, matcher := range m {
+		if matcher.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// allMatcher is the Matcher returned by All.
+type allMatcher []Matcher
+
+// MatchString implements Matcher.MatchString.
+func (m allMatcher) MatchString(s string) bool {
+	for _, matcher := range m {
+		if !matcher.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// notMatcher is the Matcher returned by Not.
+type notMatcher struct {
+	// m is the negated matcher.
+	m Matcher
+}
+
+// MatchString implements Matcher.MatchString.
+func (m notMatcher) MatchString(s string) bool {
+	return !m.m.MatchString(s)
+}
+
+// pathMatcher is the Matcher returned by PathPattern. It holds a matcher for
+// each pattern segment, or nil for "**".
+type pathMatcher []Matcher
+
+// MatchString implements Matcher.MatchString.
+func (m pathMatcher) MatchString(s string) bool {
+	return matchSegments(m, strings.Split(s, "."))
+}
+
+// matchSegments reports whether the specified path segments match the
+// specified pattern segments.
+func matchSegments(pattern []Matcher, segments []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == nil {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || !pattern[0].MatchString(segments[0]) {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,193 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// matcherCase is a test case for a matcher.
+type matcherCase struct {
+	m       Matcher
+	matches []string
+	misses  []string
+}
+
+// TestMatchers tests the matcher constructors.
+func TestMatchers(t *testing.T) {
+	for i, c := range []matcherCase{
+		{
+			m:       Glob("Password*"),
+			matches: []string{"Password", "PasswordHash"},
+			misses:  []string{"OldPassword", "password"},
+		},
+		{
+			m:       Glob("?in"),
+			matches: []string{"Pin", "Üin"},
+			misses:  []string{"in", "Spin"},
+		},
+		{
+			m:       Glob(`Weird\*.(name)`),
+			matches: []string{"Weird*.(name)"},
+			misses:  []string{"Weirdo.(name)", "Weird*x(name)"},
+		},
+		{
+			m:       Exact("Token", "Secret"),
+			matches: []string{"Token", "Secret"},
+			misses:  []string{"token", "Tokens", ""},
+		},
+		{
+			m:       CaseInsensitive("token", "api*key"),
+			matches: []string{"Token", "TOKEN", "APIKey", "ApiPrivateKey"},
+			misses:  []string{"Tokens", "APIKeys"},
+		},
+		{
+			m:       Any(Exact("A"), Glob("B*")),
+			matches: []string{"A", "B", "Bee"},
+			misses:  []string{"AB", "C"},
+		},
+		{
+			m:      Any(),
+			misses: []string{"", "A"},
+		},
+		{
+			m:       All(Glob("*Key"), Not(Exact("PublicKey"))),
+			matches: []string{"Key", "PrivateKey"},
+			misses:  []string{"PublicKey", "Keys"},
+		},
+		{
+			m:       All(),
+			matches: []string{"", "A"},
+		},
+		{
+			m: PathPattern("**.Credentials.*"),
+			matches: []string{
+				"Credentials.Password", "User.Credentials.Token",
+				"A.B.Credentials.C",
+			},
+			misses: []string{
+				"Credentials", "User.Credentials", "Credentials.A.B",
+				"UserCredentials.Token",
+			},
+		},
+		{
+			m:       PathPattern("User.**"),
+			matches: []string{"User", "User.Name", "User.Address.City"},
+			misses:  []string{"Users.Name", "Admin.User"},
+		},
+		{
+			m:       PathPattern("*.Pass*"),
+			matches: []string{"User.Password", "Admin.Passphrase"},
+			misses:  []string{"Password", "A.B.Password"},
+		},
+		{
+			m:       PathPattern("**.**.Secret"),
+			matches: []string{"Secret", "A.Secret", "A.B.Secret"},
+			misses:  []string{"Secret.A"},
+		},
+	} {
+		for _, s := range c.matches {
+			if !c.m.MatchString(s) {
+				t.Errorf("Case %d: expected match for %q", i, s)
+			}
+		}
+		for _, s := range c.misses {
+			if c.m.MatchString(s) {
+				t.Errorf("Case %d: unexpected match for %q", i, s)
+			}
+		}
+	}
+}
+
+// Credentials is a structure type for testing path filters.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// PathStruct is a structure type for testing path filters.
+type PathStruct struct {
+	Name        string
+	Credentials Credentials
+	Backup      *PathStruct
+	Admins      []Admin
+}
+
+// Admin is a structure type for testing path filters.
+type Admin struct {
+	User string
+	Key  string
+}
+
+// TestPathFilter tests filtering fields by path.
+func TestPathFilter(t *testing.T) {
+	filter := New(
+		PathFilter(PathPattern("**.Credentials.Password"), ActionRemove),
+		PathFilter(PathPattern("Admins.K*"), ActionRedact),
+		PathFilter(nil, ActionRemove),
+	)
+	value := PathStruct{
+		Name:        "main",
+		Credentials: Credentials{User: "alice", Password: "secret"},
+		Admins:      []Admin{{User: "root", Key: "k"}},
+	}
+	converted, err := filter.Convert(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Name":"main","Credentials":{"User":"alice"},"Backup":null,` +
+		`"Admins":[{"User":"root","Key":"[REDACTED]"}]}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+// CredentialsHolder is a structure type reaching Credentials through a path
+// PathPattern("**.Credentials.Password") does not match.
+type CredentialsHolder struct {
+	Creds Credentials
+}
+
+// TestPathFilterOrder tests that path filters do not depend on the order in
+// which types are converted.
+func TestPathFilterOrder(t *testing.T) {
+	filter := New(
+		PathFilter(PathPattern("**.Credentials.Password"), ActionRemove),
+	)
+	creds := Credentials{User: "bob", Password: "hunter2"}
+	if _, err := filter.Convert(CredentialsHolder{Creds: creds}); err != nil {
+		t.Fatal(err)
+	}
+	value := PathStruct{
+		Credentials: creds,
+		Backup:      &PathStruct{Credentials: creds},
+	}
+	converted, err := filter.Convert(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Name":"","Credentials":{"User":"bob"},"Backup":{"Name":"",` +
+		`"Credentials":{"User":"bob"},"Backup":null,"Admins":null},` +
+		`"Admins":null}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	converted, err = filter.Convert(CredentialsHolder{Creds: creds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(converted); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"Creds":{"User":"bob","Password":"hunter2"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
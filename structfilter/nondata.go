@@ -0,0 +1,99 @@
+package structfilter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// NonData describes how a structure filter treats types of non-data kinds,
+// i. e., channels, functions, and unsafe pointers. Values of these kinds
+// cannot be meaningfully published, and most encoders fail on them.
+//
+// The policy applies to types of non-data kinds wherever they appear without
+// going through a structure type: as a structure field type, as the element
+// type of an array, slice, or pointer, or as the key or value type of a map.
+// It also applies to values of non-data kinds found in interfaces during
+// conversion.
+type NonData int
+
+const (
+	// NonDataKeep keeps values of non-data kinds as they are. This is the
+	// default.
+	NonDataKeep NonData = iota
+
+	// NonDataDrop drops values of non-data kinds. A structure field whose type
+	// involves a non-data kind is removed from the filtered structure. An
+	// interface holding such a value is converted to nil.
+	NonDataDrop
+
+	// NonDataDescribe replaces values of non-data kinds with a string
+	// describing their type.
+	NonDataDescribe
+
+	// NonDataError causes filtering to fail with ErrNonData.
+	NonDataError
+)
+
+// ErrNonData is returned (possibly wrapped) if the non-data policy is
+// NonDataError and a structure filter encounters a non-data kind.
+var ErrNonData = errors.New("channel, function, or unsafe pointer")
+
+// errDrop is used internally to signal that a type has to be dropped.
+var errDrop = errors.New("type dropped")
+
+// stringType is the reflect type of a plain string.
+var stringType = reflect.TypeOf("")
+
+// SetNonData sets the policy for types of non-data kinds. Changing the policy
+// discards all filtered types created so far.
+func (t *T) SetNonData(policy NonData) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.nonData = policy
+	t.resetTypes()
+}
+
+// isNonData reports whether kind is a non-data kind.
+func isNonData(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	default:
+		return false
+	}
+}
+
+// mapNonData maps the original type orig of a non-data kind according to the
+// non-data policy. If the type has to be dropped, mapNonData returns errDrop.
+func (t *T) mapNonData(orig reflect.Type) (reflect.Type, error) {
+	switch t.nonData {
+	case NonDataDrop:
+		return nil, errDrop
+	case NonDataDescribe:
+		return stringType, nil
+	case NonDataError:
+		return nil, fmt.Errorf("%s: %w", orig, ErrNonData)
+	default:
+		return orig, nil
+	}
+}
+
+// convertNonData assigns the original value of a non-data kind to
+// filteredValue according to the non-data policy.
+func (t *T) convertNonData(origValue, filteredValue reflect.Value) {
+	if t.nonData == NonDataDescribe {
+		filteredValue.SetString(describeNonData(origValue))
+		return
+	}
+	filteredValue.Set(origValue)
+}
+
+// describeNonData returns a description of value, which must be of a
+// non-data kind.
+func describeNonData(value reflect.Value) string {
+	if value.IsNil() {
+		return fmt.Sprintf("%s(nil)", value.Type())
+	}
+	return value.Type().String()
+}
@@ -0,0 +1,143 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// NonDataStruct is a structure type with fields of non-data kinds for
+// testing.
+type NonDataStruct struct {
+	Name      string
+	Chan      chan int
+	Func      func()
+	Unsafe    unsafe.Pointer
+	Funcs     []func()
+	Chans     map[string]chan int
+	Interface interface{}
+}
+
+// testNonDataValue returns a value with fields of non-data kinds for testing.
+func testNonDataValue() NonDataStruct {
+	return NonDataStruct{
+		Name:      "test",
+		Chan:      make(chan int),
+		Func:      func() {},
+		Funcs:     []func(){nil},
+		Chans:     map[string]chan int{"ch": nil},
+		Interface: make(chan string),
+	}
+}
+
+// TestNonDataKeep tests the default non-data policy.
+func TestNonDataKeep(t *testing.T) {
+	filter := New()
+	filtered, err := filter.Convert(testNonDataValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(filtered).NumField() != 7 {
+		t.Error("Expected all fields to be kept")
+	}
+}
+
+// TestNonDataDrop tests dropping non-data kinds.
+func TestNonDataDrop(t *testing.T) {
+	filter := New()
+	filter.SetNonData(NonDataDrop)
+	filtered, err := filter.Convert(testNonDataValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	if value.NumField() != 2 {
+		t.Errorf("Expected 2 remaining fields, got %d", value.NumField())
+	}
+	if !value.FieldByName("Interface").IsNil() {
+		t.Error("Expected channel in interface to be dropped")
+	}
+	if _, err := json.Marshal(filtered); err != nil {
+		t.Errorf("Unable to marshal filtered value: %s", err)
+	}
+	if filtered, err = filter.Convert(func() {}); err != nil || filtered != nil {
+		t.Errorf("Expected (nil, nil) for top level function, got %v, %v",
+			filtered, err)
+	}
+	report, err := filter.Explain(reflect.TypeOf(NonDataStruct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range report.Structs[0].Fields {
+		if f.Kept == f.NonData {
+			t.Errorf("Unexpected report for field %s: %+v", f.Name, f)
+		}
+	}
+}
+
+// TestNonDataDropRoot tests explaining and rendering dropped root types.
+func TestNonDataDropRoot(t *testing.T) {
+	filter := New()
+	filter.SetNonData(NonDataDrop)
+	typ := reflect.TypeOf(make(chan int))
+	report, err := filter.Explain(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Dropped || report.String() != "chan int => dropped\n" {
+		t.Errorf("Unexpected report for dropped type: %+v", report)
+	}
+	src, err := filter.GoSource(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "type Filtered interface{} // dropped\n"; src != expected {
+		t.Errorf("Expected source '%s', got '%s'", expected, src)
+	}
+}
+
+// TestNonDataDescribe tests replacing non-data kinds with descriptions.
+func TestNonDataDescribe(t *testing.T) {
+	filter := New()
+	filter.SetNonData(NonDataDescribe)
+	filtered, err := filter.Convert(testNonDataValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered)
+	for name, expected := range map[string]interface{}{
+		"Chan":      "chan int",
+		"Func":      "func()",
+		"Unsafe":    "unsafe.Pointer(nil)",
+		"Interface": "chan string",
+	} {
+		if v := value.FieldByName(name).Interface(); v != expected {
+			t.Errorf("Expected description '%s' for %s, got %v", expected, name, v)
+		}
+	}
+	if v := value.FieldByName("Funcs").Interface(); !reflect.DeepEqual(
+		v, []string{"func()(nil)"}) {
+		t.Errorf("Unexpected description for Funcs: %v", v)
+	}
+	if _, err := json.Marshal(filtered); err != nil {
+		t.Errorf("Unable to marshal filtered value: %s", err)
+	}
+}
+
+// TestNonDataError tests failing on non-data kinds.
+func TestNonDataError(t *testing.T) {
+	filter := New()
+	filter.SetNonData(NonDataError)
+	if _, err := filter.Convert(testNonDataValue()); !errors.Is(err, ErrNonData) {
+		t.Errorf("Expected non-data error, got: %v", err)
+	}
+	type dataOnly struct {
+		Interface interface{}
+	}
+	_, err := filter.Convert(dataOnly{Interface: make(chan int)})
+	if !errors.Is(err, ErrNonData) {
+		t.Errorf("Expected non-data error for interface value, got: %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+// Package pii provides content-based detection and masking of personal and
+// secret data in strings, for use as string functions with the structfilter
+// package:
+//
+//     filter.SetStringFilters(pii.New().Filter)
+//
+// Field names alone miss free text fields such as notes or descriptions,
+// where people paste e-mail addresses, card numbers, or access tokens. A
+// Scrubber looks into every string value instead.
+package pii
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector detects a certain kind of data in strings.
+type Detector interface {
+	// Name returns a short upper case name for the kind of data detected,
+	// e. g., "EMAIL".
+	Name() string
+
+	// Find returns the start and end offsets of all matches in s, in
+	// ascending, non-overlapping order.
+	Find(s string) [][]int
+}
+
+// RegexpDetector returns a detector with the specified name which detects
+// matches of re. If re has a capturing group, only the first group of each
+// match is detected, so that context such as a keyword preceding a secret can
+// be part of the expression without being masked. If valid is not nil, only
+// matches for which valid returns true are detected. As re matches as much as
+// possible, a valid match may be followed by text re matches as well, e. g., a
+// card number by an expiry date. So if valid returns false, shorter candidates
+// ending where a letter or digit is followed by another character are tried,
+// longest first, provided re matches them in full.
+func RegexpDetector(
+	name string, re *regexp.Regexp, valid func(match string) bool,
+) Detector {
+	d := &regexpDetector{
+		name:  name,
+		re:    re,
+		valid: valid,
+	}
+	if valid != nil {
+		d.full = regexp.MustCompile(`^(?:` + re.String() + `)$`)
+	}
+	return d
+}
+
+// regexpDetector is a Detector based on a regular expression.
+type regexpDetector struct {
+	// name is the name of the detector.
+	name string
+
+	// re is the regular expression.
+	re *regexp.Regexp
+
+	// valid validates matches, or is nil.
+	valid func(string) bool
+
+	// full matches re against an entire candidate, or is nil if valid is nil.
+	full *regexp.Regexp
+}
+
+// Name implements Detector.Name.
+func (d *regexpDetector) Name() string {
+	return d.name
+}
+
+// Find implements Detector.Find.
+func (d *regexpDetector) Find(s string) [][]int {
+	var result [][]int
+	for _, match := range d.re.FindAllStringSubmatchIndex(s, -1) {
+		if detected := d.detect(s, match); detected != nil {
+			result = append(result, detected)
+			continue
+		}
+		if d.valid == nil {
+			continue
+		}
+		// Try shorter candidates ending on a group boundary.
+		start := match[0]
+		for end := match[1] - 1; end > start; end-- {
+			if !isAlnum(s[end-1]) || isAlnum(s[end]) {
+				continue
+			}
+			candidate := d.full.FindStringSubmatchIndex(s[start:end])
+			if candidate == nil {
+				continue
+			}
+			for i := range candidate {
+				if candidate[i] >= 0 {
+					candidate[i] += start
+				}
+			}
+			if detected := d.detect(s, candidate); detected != nil {
+				result = append(result, detected)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// detect returns the start and end offsets of the detected part of the
+// specified submatch indices into s, or nil if nothing is detected.
+func (d *regexpDetector) detect(s string, match []int) []int {
+	if len(match) >= 4 {
+		if match[2] < 0 {
+			return nil
+		}
+		match = match[2:4]
+	} else {
+		match = match[:2]
+	}
+	if d.valid != nil && !d.valid(s[match[0]:match[1]]) {
+		return nil
+	}
+	return match
+}
+
+// isAlnum reports whether c is an ASCII letter or digit.
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// Built-in detectors.
+var (
+	// Email detects e-mail addresses.
+	Email = RegexpDetector("EMAIL", regexp.MustCompile(
+		`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`,
+	), nil)
+
+	// CreditCard detects payment card numbers with 13 to 19 digits, possibly
+	// grouped with spaces or dashes, which pass the Luhn check.
+	CreditCard = RegexpDetector("CARD", regexp.MustCompile(
+		`\b\d(?:[ \-]?\d){12,18}\b`,
+	), validCard)
+
+	// IBAN detects international bank account numbers, possibly grouped with
+	// spaces, which pass the ISO 7064 mod 97 check.
+	IBAN = RegexpDetector("IBAN", regexp.MustCompile(
+		`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`,
+	), validIBAN)
+
+	// Phone detects phone numbers in international format, starting with a
+	// plus sign, or with an area code in parentheses.
+	Phone = RegexpDetector("PHONE", regexp.MustCompile(
+		`(?:\+\d{1,3}[ .\-]?(?:\(\d{1,4}\)[ .\-]?)?|\(\d{2,4}\)[ .\-]?)`+
+			`\d{2,4}(?:[ .\-]?\d{2,4}){1,4}\b`,
+	), validPhone)
+
+	// JWT detects JSON web tokens.
+	JWT = RegexpDetector("JWT", regexp.MustCompile(
+		`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`,
+	), nil)
+
+	// AWSAccessKey detects AWS access key IDs.
+	AWSAccessKey = RegexpDetector("AWS_ACCESS_KEY", regexp.MustCompile(
+		`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b`,
+	), nil)
+
+	// AWSSecretKey detects AWS secret access keys following a key name such
+	// as aws_secret_access_key.
+	AWSSecretKey = RegexpDetector("AWS_SECRET_KEY", regexp.MustCompile(
+		`(?i)aws_?secret(?:_?access)?_?key["']?\s*[:=]\s*["']?`+
+			`([A-Za-z0-9/+]{40})\b`,
+	), nil)
+
+	// BearerToken detects bearer tokens following the word "Bearer", as in an
+	// HTTP Authorization header.
+	BearerToken = RegexpDetector("BEARER_TOKEN", regexp.MustCompile(
+		`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	), nil)
+)
+
+// DefaultDetectors returns all built-in detectors, in the order a Scrubber
+// created by New without detectors applies them.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		JWT, BearerToken, AWSAccessKey, AWSSecretKey, Email, IBAN, CreditCard,
+		Phone,
+	}
+}
+
+// Scrubber masks the data found by its detectors.
+type Scrubber struct {
+	// detectors are the detectors, in order.
+	detectors []Detector
+
+	// mask returns the replacement for a match.
+	mask func(name, match string) string
+}
+
+// New creates a new Scrubber applying the specified detectors in order. If
+// no detectors are specified, DefaultDetectors are applied.
+func New(detectors ...Detector) *Scrubber {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+	return &Scrubber{
+		detectors: detectors,
+		mask:      DefaultMask,
+	}
+}
+
+// DefaultMask is the default mask function. It replaces matches with a
+// placeholder like structfilter.Redacted which includes the name of the
+// detector, e. g., "[REDACTED:EMAIL]".
+func DefaultMask(name, match string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// SetMask sets the function returning the replacement for a match found by
+// the detector with the specified name. The default is DefaultMask.
+func (s *Scrubber) SetMask(mask func(name, match string) string) {
+	s.mask = mask
+}
+
+// Scrub returns text with all matches of the detectors masked. The detectors
+// are applied in order, each to the output of the previous one.
+func (s *Scrubber) Scrub(text string) string {
+	for _, detector := range s.detectors {
+		matches := detector.Find(text)
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i][0] < matches[j][0]
+		})
+		var sb strings.Builder
+		last := 0
+		for _, match := range matches {
+			if match[0] < last {
+				continue // overlapping
+			}
+			sb.WriteString(text[last:match[0]])
+			sb.WriteString(s.mask(detector.Name(), text[match[0]:match[1]]))
+			last = match[1]
+		}
+		sb.WriteString(text[last:])
+		text = sb.String()
+	}
+	return text
+}
+
+// Filter is like Scrub, but has the signature of a structfilter.StringFunc.
+// It never returns an error.
+func (s *Scrubber) Filter(ctx context.Context, text string) (string, error) {
+	return s.Scrub(text), nil
+}
+
+// digits returns the decimal digits in s.
+func digits(s string) []byte {
+	result := make([]byte, 0, len(s))
+	for i := 0; i != len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			result = append(result, s[i]-'0')
+		}
+	}
+	return result
+}
+
+// validCard reports whether the digits in s form a payment card number which
+// passes the Luhn check.
+func validCard(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range d {
+		digit := int(d[len(d)-1-i])
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether s, with spaces removed, is an IBAN which passes
+// the ISO 7064 mod 97 check.
+func validIBAN(s string) bool {
+	s = strings.Replace(s, " ", "", -1)
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	s = s[4:] + s[:4]
+	remainder := 0
+	for i := 0; i != len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// validPhone reports whether s contains between 7 and 15 digits, the range
+// of valid international phone numbers.
+func validPhone(s string) bool {
+	n := len(digits(s))
+	return n >= 7 && n <= 15
+}
@@ -0,0 +1,103 @@
+package structfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// registry holds the types registered with Register.
+var registry struct {
+	sync.Mutex
+
+	// types lists the registered types in order of registration.
+	types []reflect.Type
+
+	// seen holds the registered types.
+	seen map[reflect.Type]bool
+}
+
+// Register adds the types of the specified values to a global registry of
+// types to be precompiled with T.PrecompileRegistered. Each value may be a
+// reflect.Type, or a value of the type to be registered, e. g., User{} or
+// (*User)(nil). Packages defining types meant for filtering can register them
+// in an init function, so that the types are filtered when the program
+// starts. Register panics if a value is nil. It is safe for concurrent use.
+func Register(values ...interface{}) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.seen == nil {
+		registry.seen = make(map[reflect.Type]bool)
+	}
+	for _, value := range values {
+		typ := precompileType(value)
+		if typ == nil {
+			panic("structfilter: Register of nil value")
+		}
+		if !registry.seen[typ] {
+			registry.seen[typ] = true
+			registry.types = append(registry.types, typ)
+		}
+	}
+}
+
+// Registered returns the types registered with Register, in order of
+// registration.
+func Registered() []reflect.Type {
+	registry.Lock()
+	defer registry.Unlock()
+	return append([]reflect.Type(nil), registry.types...)
+}
+
+// Precompile creates the filtered types for the types of the specified
+// values, see Register for what the values may be. Filter functions are
+// called for all structure types reachable from the specified types, so
+// errors surface early instead of during the first conversion, and later
+// conversions of values of these types find the filtered types in the cache.
+// Precompile stops at the first error. Types dropped entirely, e. g.,
+// according to the non-data policy, are not an error.
+func (t *T) Precompile(values ...interface{}) error {
+	return t.PrecompileContext(context.Background(), values...)
+}
+
+// PrecompileContext is like Precompile, but passes ctx to the filter
+// functions, see Field.Context. If ctx carries a profile, see WithProfile,
+// the types are precompiled for that profile.
+func (t *T) PrecompileContext(
+	ctx context.Context, values ...interface{},
+) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, value := range values {
+		typ := precompileType(value)
+		if typ == nil {
+			return errors.New("cannot precompile nil")
+		}
+		if _, err := t.mapRootType(ctx, typ); err != nil {
+			return fmt.Errorf("%s: %w", typ, err)
+		}
+	}
+	return nil
+}
+
+// PrecompileRegistered precompiles all types registered with Register, see
+// Precompile.
+func (t *T) PrecompileRegistered() error {
+	types := Registered()
+	values := make([]interface{}, len(types))
+	for i, typ := range types {
+		values[i] = typ
+	}
+	return t.Precompile(values...)
+}
+
+// precompileType returns the type to be precompiled for the specified value,
+// or nil if value is nil.
+func precompileType(value interface{}) reflect.Type {
+	if typ, ok := value.(reflect.Type); ok {
+		return typ
+	}
+	return reflect.TypeOf(value)
+}
@@ -0,0 +1,75 @@
+package structfilter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestPrecompile tests precompiling types.
+func TestPrecompile(t *testing.T) {
+	filter := New()
+	if err := filter.Precompile(
+		Team{}, reflect.TypeOf(map[string]*[]Credentials{}),
+	); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"structfilter.Credentials", "structfilter.Member", "structfilter.Team",
+	}
+	if names := cachedTypeNames(filter); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cached types %v, got %v", expected, names)
+	}
+	misses := filter.Stats().Misses
+	if _, err := filter.Convert(&testTeam); err != nil {
+		t.Fatal(err)
+	}
+	if filter.Stats().Misses != misses {
+		t.Error("Expected no cache misses after precompiling")
+	}
+	if err := filter.Precompile(nil); err == nil {
+		t.Error("Expected error precompiling nil")
+	}
+	filter.SetNonData(NonDataDrop)
+	if err := filter.Precompile(make(chan int)); err != nil {
+		t.Errorf("Expected dropped type to precompile, got %s", err)
+	}
+	filter = New(When(NameMatches(Exact("Name")), keepFilter))
+	filter.SetDefault(DefaultError)
+	if err := filter.Precompile((*Team)(nil)); !errors.Is(err, ErrUndecided) {
+		t.Errorf("Expected ErrUndecided, got %v", err)
+	}
+}
+
+// TestPrecompileRegistered tests precompiling registered types.
+func TestPrecompileRegistered(t *testing.T) {
+	registry.Lock()
+	savedTypes, savedSeen := registry.types, registry.seen
+	registry.types, registry.seen = nil, nil
+	registry.Unlock()
+	defer func() {
+		registry.Lock()
+		registry.types, registry.seen = savedTypes, savedSeen
+		registry.Unlock()
+	}()
+	Register(Team{}, reflect.TypeOf(Credentials{}), Team{})
+	expected := []reflect.Type{
+		reflect.TypeOf(Team{}), reflect.TypeOf(Credentials{}),
+	}
+	if types := Registered(); !reflect.DeepEqual(types, expected) {
+		t.Errorf("Expected registered types %v, got %v", expected, types)
+	}
+	filter := New()
+	if err := filter.PrecompileRegistered(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(filter.CachedTypes()); n != 3 {
+		t.Errorf("Expected 3 cached types, got %d", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic registering nil")
+		}
+	}()
+	Register(nil)
+}
@@ -0,0 +1,40 @@
+package structfilter
+
+import (
+	"context"
+)
+
+// profileKey is the context key for the profile.
+type profileKey struct{}
+
+// WithProfile returns a copy of ctx carrying the specified profile. When a
+// structure filter converts a value with the returned context, see
+// T.ConvertContext, filter functions see the profile via Field.Profile.
+//
+// A structure filter keeps a separate cache of filtered types for each
+// profile, so a single filter can produce different views of the same type,
+// e. g., depending on the role of the user a value is presented to. Without a
+// profile, the empty profile is used. The profile is the only value carried by
+// a context which filter decisions may depend on, see Field.Context.
+func WithProfile(ctx context.Context, profile string) context.Context {
+	return context.WithValue(ctx, profileKey{}, profile)
+}
+
+// profileFromContext returns the profile carried by ctx, or the empty profile
+// if ctx carries none.
+func profileFromContext(ctx context.Context) string {
+	profile, _ := ctx.Value(profileKey{}).(string)
+	return profile
+}
+
+// ConvertFor is like Convert, but converts in for the specified profile, see
+// WithProfile.
+func (t *T) ConvertFor(profile string, in interface{}) (interface{}, error) {
+	return t.ConvertContext(WithProfile(context.Background(), profile), in)
+}
+
+// EncodeJSONFor is like EncodeJSON, but encodes v for the specified profile,
+// see WithProfile.
+func (t *T) EncodeJSONFor(profile string, v interface{}) ([]byte, error) {
+	return t.EncodeJSONContext(WithProfile(context.Background(), profile), v)
+}
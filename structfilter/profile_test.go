@@ -0,0 +1,75 @@
+package structfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestConvertFor tests converting values for different profiles.
+func TestConvertFor(t *testing.T) {
+	calls := 0
+	filter := New(func(f *Field) error {
+		calls++
+		if f.Profile() != "admin" && f.Name() == "Remove1" {
+			f.Remove()
+		}
+		return nil
+	})
+	orig := StructKeepRemove{Remove1: 42}
+	for i := 0; i != 2; i++ {
+		admin, err := filter.ConvertFor("admin", orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := reflect.ValueOf(admin).FieldByName("Remove1"); !v.IsValid() ||
+			v.Interface().(int) != 42 {
+			t.Error("Expected field Remove1 for admin profile")
+		}
+		anonymous, err := filter.Convert(orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.ValueOf(anonymous).FieldByName("Remove1").IsValid() {
+			t.Error("Expected field Remove1 to be removed for empty profile")
+		}
+	}
+	if calls != 8 {
+		t.Errorf("Expected types to be filtered once per profile, got %d calls",
+			calls)
+	}
+}
+
+// TestEncodeJSONFor tests encoding values for different profiles.
+func TestEncodeJSONFor(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.Profile() != "admin" && f.Name() == "Remove1" {
+			f.Remove()
+		}
+		return nil
+	})
+	orig := StructKeepRemove{Remove1: 42}
+	for _, profile := range []string{"admin", ""} {
+		data, err := filter.EncodeJSONFor(profile, orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := filter.ConvertFor(profile, orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := json.Marshal(converted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected %s for profile '%s', got %s", expected, profile,
+				data)
+		}
+		if admin := bytes.Contains(data, []byte(`"Remove1"`)); admin !=
+			(profile == "admin") {
+			t.Errorf("Unexpected encoding for profile '%s': %s", profile, data)
+		}
+	}
+}
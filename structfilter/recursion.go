@@ -0,0 +1,175 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Recursion describes how recursive types are represented in filtered types.
+//
+// The reflect package cannot create recursive types. Whenever a filtered type
+// would have to refer to itself, directly or indirectly, the recursion is cut
+// at the innermost possible point, i. e., at the pointer, slice element, array
+// element, or map value which refers back to a structure type still being
+// filtered, or to a named array, map, pointer, or slice type still being
+// mapped, e. g., type M map[string]M. The cut is replaced with one of the
+// marker types Recursive, RecursiveJSON, or RecursiveMap. A map whose key
+// type is recursive is cut as a whole.
+type Recursion int
+
+const (
+	// RecursionInterface replaces recursive references with the Recursive
+	// interface type, which holds the converted value. This is the default.
+	RecursionInterface Recursion = iota
+
+	// RecursionJSON replaces recursive references with the RecursiveJSON type,
+	// which holds the JSON encoding of the converted value. Cyclic values are
+	// unrolled until a value is revisited.
+	RecursionJSON
+
+	// RecursionMap replaces recursive references with the RecursiveMap type.
+	// A converted structure is stored as a map from its JSON field names to
+	// its field values, honouring the omitempty option. Nil pointers become
+	// nil maps, and maps cut as a whole are stored with their keys formatted
+	// by the fmt package. Arrays and slices, which are cut only if they are of
+	// a recursive named type, are stored with their indices as keys. Cyclic
+	// values are unrolled until a value is revisited.
+	RecursionMap
+)
+
+// Recursive is the marker type used in place of recursive references with
+// the RecursionInterface strategy. A nil original pointer, map, or slice
+// yields a nil Recursive.
+type Recursive interface{}
+
+// RecursiveJSON is the marker type used in place of recursive references
+// with the RecursionJSON strategy.
+type RecursiveJSON []byte
+
+// MarshalJSON returns r as is, or null if r is nil.
+func (r RecursiveJSON) MarshalJSON() ([]byte, error) {
+	if r == nil {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// RecursiveMap is the marker type used in place of recursive references with
+// the RecursionMap strategy.
+type RecursiveMap map[string]interface{}
+
+// Reflect types of the recursion marker types.
+var (
+	recursiveType     = reflect.TypeOf(new(Recursive)).Elem()
+	recursiveJSONType = reflect.TypeOf(RecursiveJSON(nil))
+	recursiveMapType  = reflect.TypeOf(RecursiveMap(nil))
+)
+
+// SetRecursion sets the strategy for representing recursive types. Changing
+// the strategy discards all filtered types created so far.
+func (t *T) SetRecursion(recursion Recursion) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.recursion = recursion
+	t.resetTypes()
+}
+
+// cutType returns the marker type for the recursion strategy of t.
+func (t *T) cutType() reflect.Type {
+	switch t.recursion {
+	case RecursionJSON:
+		return recursiveJSONType
+	case RecursionMap:
+		return recursiveMapType
+	default:
+		return recursiveType
+	}
+}
+
+// isCutType reports whether typ is one of the recursion marker types.
+func isCutType(typ reflect.Type) bool {
+	return typ == recursiveType || typ == recursiveJSONType ||
+		typ == recursiveMapType
+}
+
+// containsCut reports whether the filtered type typ contains a recursion
+// marker type without going through a structure type.
+func containsCut(typ reflect.Type) bool {
+	if isCutType(typ) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Array, reflect.Ptr, reflect.Slice:
+		return containsCut(typ.Elem())
+	case reflect.Map:
+		return containsCut(typ.Key()) || containsCut(typ.Elem())
+	default:
+		return false
+	}
+}
+
+// convertCut converts the specified original value to the recursion marker
+// type of filteredValue, which must be either RecursiveJSON or RecursiveMap.
+// For info on state, depth, and path, see T.convertValue().
+func (t *T) convertCut(
+	state *convertState, depth int, path string,
+	origValue, filteredValue reflect.Value,
+) error {
+	converted := reflect.New(interfaceType).Elem()
+	if err := t.convertValue(
+		state, depth, path, origValue, converted,
+	); err != nil {
+		return err
+	}
+	if converted.IsNil() {
+		return nil
+	}
+	if filteredValue.Type() == recursiveJSONType {
+		data, err := json.Marshal(converted.Interface())
+		if err != nil {
+			return fmt.Errorf("recursive JSON: %w", err)
+		}
+		filteredValue.SetBytes(data)
+		return nil
+	}
+	converted = converted.Elem()
+	for converted.Kind() == reflect.Ptr {
+		converted = converted.Elem()
+	}
+	switch converted.Kind() {
+	case reflect.Struct:
+		node := make(RecursiveMap, converted.NumField())
+		for i := 0; i != converted.NumField(); i++ {
+			name, opts, ok := jsonField(converted.Type().Field(i))
+			if !ok {
+				continue
+			}
+			if hasJSONOption(opts, "omitempty") && isEmptyJSON(converted.Field(i)) {
+				continue
+			}
+			node[name] = converted.Field(i).Interface()
+		}
+		filteredValue.Set(reflect.ValueOf(node))
+	case reflect.Map:
+		node := make(RecursiveMap, converted.Len())
+		iter := converted.MapRange()
+		for iter.Next() {
+			node[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		filteredValue.Set(reflect.ValueOf(node))
+	case reflect.Array, reflect.Slice:
+		node := make(RecursiveMap, converted.Len())
+		for i := 0; i != converted.Len(); i++ {
+			node[strconv.Itoa(i)] = converted.Index(i).Interface()
+		}
+		filteredValue.Set(reflect.ValueOf(node))
+	case reflect.Invalid:
+		// nil pointer
+	default:
+		return errors.New("recursive map: not a structure, map, or list")
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TreeNode is a recursive tree structure for testing.
+type TreeNode struct {
+	Name     string `json:"name"`
+	Secret   string `json:"-"`
+	Children []*TreeNode
+	Parent   *TreeNode `json:"parent,omitempty"`
+}
+
+// testTree returns a small tree for testing.
+func testTree() *TreeNode {
+	return &TreeNode{
+		Name: "root",
+		Children: []*TreeNode{
+			{Name: "leaf1"},
+			{
+				Name:     "inner",
+				Children: []*TreeNode{{Name: "leaf2"}},
+			},
+		},
+	}
+}
+
+// TestRecursionConvert tests that all recursion strategies yield the same
+// JSON encoding as the original value.
+func TestRecursionConvert(t *testing.T) {
+	expected, err := json.Marshal(testTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recursion := range []Recursion{
+		RecursionInterface, RecursionJSON, RecursionMap,
+	} {
+		filter := New()
+		filter.SetRecursion(recursion)
+		filtered, err := filter.Convert(testTree())
+		if err != nil {
+			t.Fatalf("Error converting tree with strategy %d: %s", recursion, err)
+		}
+		data, err := json.Marshal(filtered)
+		if err != nil {
+			t.Fatalf("Error marshalling tree with strategy %d: %s", recursion, err)
+		}
+		if !jsonEqual(t, data, expected) {
+			t.Errorf("Strategy %d: expected %s, got %s", recursion, expected, data)
+		}
+	}
+}
+
+// TestRecursionInterfaceConvert tests that the Recursive marker holds
+// filtered values with the RecursionInterface strategy.
+func TestRecursionInterfaceConvert(t *testing.T) {
+	filter := New()
+	filtered, err := filter.Convert(testTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := reflect.ValueOf(filtered).Elem().FieldByName("Children")
+	if children.Type() != reflect.SliceOf(recursiveType) {
+		t.Fatalf("Unexpected type for Children: %s", children.Type())
+	}
+	child := children.Index(0).Elem()
+	if child.Type() != reflect.TypeOf(filtered) {
+		t.Errorf("Expected child to have the filtered type, got %s", child.Type())
+	}
+}
+
+// TestRecursionCyclic tests converting cyclic values with the different
+// recursion strategies.
+func TestRecursionCyclic(t *testing.T) {
+	rec1 := &RecursiveStruct{}
+	rec2 := &RecursiveStruct{Ptr: rec1}
+	rec1.Ptr = rec2
+	filter := New()
+	filter.SetRecursion(RecursionMap)
+	filtered, err := filter.Convert(rec1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := reflect.ValueOf(filtered).Elem().FieldByName("Ptr").
+		Interface().(RecursiveMap)
+	if !ok || node["Ptr"] == nil {
+		t.Errorf("Expected map node for cyclic value, got %#v", node)
+	}
+	filter.SetRecursion(RecursionJSON)
+	filtered, err = filter.Convert(rec1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := reflect.ValueOf(filtered).Elem().FieldByName("Ptr").
+		Interface().(RecursiveJSON)
+	if !json.Valid(data) {
+		t.Errorf("Invalid JSON for cyclic value: %s", data)
+	}
+}
+
+// jsonEqual reports whether the JSON documents data1 and data2 are equal up to
+// formatting and the order of object keys.
+func jsonEqual(t *testing.T, data1, data2 []byte) bool {
+	t.Helper()
+	var doc1, doc2 interface{}
+	if err := json.Unmarshal(data1, &doc1); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data2, &doc2); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(doc1, doc2)
+}
+
+// NamedMap is a recursive named map type for testing.
+type NamedMap map[string]NamedMap
+
+// NamedSlice is a recursive named slice type for testing.
+type NamedSlice []NamedSlice
+
+// NamedPtr is a recursive named pointer type for testing.
+type NamedPtr *NamedPtr
+
+// NamedHolder is a structure type holding recursive named types.
+type NamedHolder struct {
+	Map   NamedMap
+	Slice NamedSlice
+}
+
+// TestRecursionNamed tests that recursion is cut for named types other than
+// structure types.
+func TestRecursionNamed(t *testing.T) {
+	holder := NamedHolder{
+		Map:   NamedMap{"a": {"b": {}}, "c": nil},
+		Slice: NamedSlice{{}, {{}, nil}},
+	}
+	expected, err := json.Marshal(holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ptr NamedPtr
+	ptr = &ptr
+	for _, recursion := range []Recursion{
+		RecursionInterface, RecursionJSON, RecursionMap,
+	} {
+		filter := New()
+		filter.SetRecursion(recursion)
+		for _, typ := range []reflect.Type{
+			reflect.TypeOf(NamedMap(nil)), reflect.TypeOf(NamedSlice(nil)),
+			reflect.TypeOf(ptr), reflect.TypeOf(holder),
+		} {
+			if _, err := filter.MapType(typ); err != nil {
+				t.Errorf("MapType(%s): %v", typ, err)
+			}
+			if _, err := filter.Explain(typ); err != nil {
+				t.Errorf("Explain(%s): %v", typ, err)
+			}
+			if _, err := filter.GoSource(typ); err != nil {
+				t.Errorf("GoSource(%s): %v", typ, err)
+			}
+		}
+		if _, err := filter.JSONSchema(reflect.TypeOf(holder)); err != nil {
+			t.Errorf("JSONSchema: %v", err)
+		}
+		if err := filter.Precompile(holder, ptr); err != nil {
+			t.Errorf("Precompile: %v", err)
+		}
+		if _, err := filter.Convert(ptr); err != nil {
+			t.Errorf("Convert(ptr): %v", err)
+		}
+		converted, err := filter.Convert(holder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := json.Marshal(converted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recursion == RecursionMap {
+			// Cut slices are stored by index.
+			expected := `{"Map":{"a":{"b":{}},"c":null},` +
+				`"Slice":[{},{"0":{},"1":null}]}`
+			if string(actual) != expected {
+				t.Errorf("Recursion %d: expected %s, got %s",
+					recursion, expected, actual)
+			}
+		} else if string(actual) != string(expected) {
+			t.Errorf("Recursion %d: expected %s, got %s",
+				recursion, expected, actual)
+		}
+		filter.SetCacheLimit(1)
+		filter.Forget(reflect.TypeOf(holder))
+	}
+}
@@ -0,0 +1,75 @@
+package structfilter
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SafeError is printed instead of a value wrapped with Safe if the value
+// cannot be converted.
+const SafeError = "%!(structfilter: conversion failed)"
+
+// SafeValue is a value wrapped with Safe.
+type SafeValue struct {
+	// t is the structure filter.
+	t *T
+
+	// v is the wrapped value.
+	v interface{}
+}
+
+// Safe wraps v so that the fmt package prints it as converted by t, e. g., to
+// keep passwords out of logs:
+//
+//     log.Printf("%+v", structfilter.Safe(filter, user))
+//
+// v is converted lazily whenever the returned value is formatted. All verbs
+// and flags are applied to the converted value. If v cannot be converted,
+// SafeError is printed instead, never v itself. Like the methods of T,
+// formatting the returned value is safe for concurrent use.
+func Safe(t *T, v interface{}) SafeValue {
+	return SafeValue{t: t, v: v}
+}
+
+// Format implements fmt.Formatter.
+func (s SafeValue) Format(f fmt.State, verb rune) {
+	converted, err := s.convert()
+	if err != nil {
+		fmt.Fprint(f, SafeError)
+		return
+	}
+	fmt.Fprintf(f, formatString(f, verb), converted)
+}
+
+// String implements fmt.Stringer.
+func (s SafeValue) String() string {
+	converted, err := s.convert()
+	if err != nil {
+		return SafeError
+	}
+	return fmt.Sprint(converted)
+}
+
+// convert converts the wrapped value.
+func (s SafeValue) convert() (interface{}, error) {
+	return s.t.Convert(s.v)
+}
+
+// formatString reconstructs the format directive for the specified verb from
+// the flags, width, and precision of f.
+func formatString(f fmt.State, verb rune) string {
+	directive := []byte{'%'}
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			directive = append(directive, byte(flag))
+		}
+	}
+	if width, ok := f.Width(); ok {
+		directive = strconv.AppendInt(directive, int64(width), 10)
+	}
+	if precision, ok := f.Precision(); ok {
+		directive = append(directive, '.')
+		directive = strconv.AppendInt(directive, int64(precision), 10)
+	}
+	return string(directive) + string(verb)
+}
@@ -0,0 +1,80 @@
+package structfilter
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// SafeUser is a structure type for testing Safe.
+type SafeUser struct {
+	Name     string
+	Password string
+	Age      float64
+}
+
+// TestSafe tests formatting values wrapped with Safe.
+func TestSafe(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile(`^Password$`)))
+	user := &SafeUser{Name: "joe", Password: "hunter2", Age: 42.5}
+	converted, err := filter.Convert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safe := Safe(filter, user)
+	for _, format := range []string{
+		"%v", "%+v", "%#v", "%s", "%10.3v", "%-8v|", "%x",
+	} {
+		expected := fmt.Sprintf(format, converted)
+		actual := fmt.Sprintf(format, safe)
+		if actual != expected {
+			t.Errorf("%s: expected %s, got %s", format, expected, actual)
+		}
+		if regexp.MustCompile(`hunter2`).MatchString(actual) {
+			t.Errorf("%s: password leaked: %s", format, actual)
+		}
+	}
+	if s := safe.String(); s != fmt.Sprint(converted) {
+		t.Errorf("Expected %v, got %s", converted, s)
+	}
+	if s := fmt.Sprint(Safe(filter, nil)); s != "<nil>" {
+		t.Errorf("Expected <nil>, got %s", s)
+	}
+}
+
+// TestSafeError tests formatting values wrapped with Safe which cannot be
+// converted.
+func TestSafeError(t *testing.T) {
+	filter := New(errorFilter)
+	safe := Safe(filter, SafeUser{Password: "hunter2"})
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if s := fmt.Sprintf(format, safe); s != SafeError {
+			t.Errorf("%s: expected %s, got %s", format, SafeError, s)
+		}
+	}
+	if s := safe.String(); s != SafeError {
+		t.Errorf("Expected %s, got %s", SafeError, s)
+	}
+}
+
+// TestSafeConcurrent tests formatting values wrapped with the same structure
+// filter from several goroutines. Run with the race detector.
+func TestSafeConcurrent(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile(`^Password$`)))
+	var wg sync.WaitGroup
+	for i := 0; i != 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j != 16; j++ {
+				user := SafeUser{Name: "joe", Password: "hunter2", Age: float64(i)}
+				s := fmt.Sprintf("%+v %s", Safe(filter, user), Safe(filter, &user))
+				if regexp.MustCompile(`hunter2`).MatchString(s) {
+					t.Errorf("Password leaked: %s", s)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,457 @@
+package structfilter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// jsonSchemaDialect is the URI of the JSON Schema dialect emitted by
+// T.JSONSchema.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the JSON
+// encoding of the values Convert returns for values of type orig, i. e., the
+// filtered output rather than the original type.
+//
+// The schema honours json tags as changed by the filter functions: names, the
+// "-" name, the omitempty option, which makes a property optional, and the
+// string option. Redacted fields and values of types redacted by type, see
+// TypeFunc, are described as strings. Named structure types are described in
+// the $defs section of the schema and referred to with $ref, so that
+// recursive types are described without cuts, regardless of the recursion
+// strategy, see T.SetRecursion. Named recursive types of other kinds, e. g.,
+// type M map[string]M, are described by the empty schema where they recur.
+// Interfaces are described by the empty schema, as their dynamic types are
+// not known in advance.
+//
+// JSONSchema fails for types the encoding/json package cannot encode, e. g.,
+// complex numbers, or channels with the default non-data policy.
+func (t *T) JSONSchema(orig reflect.Type) ([]byte, error) {
+	if orig == nil {
+		return nil, errors.New("orig is nil")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	g := t.newSchemaGenerator("#/$defs/")
+	t.useRoot("", orig)
+	root, err := g.schema("", orig)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{}, len(root)+2)
+	for key, value := range root {
+		doc[key] = value
+	}
+	doc["$schema"] = jsonSchemaDialect
+	if len(g.defs) != 0 {
+		doc["$defs"] = g.defs
+	}
+	return json.Marshal(doc)
+}
+
+// JSONSchemaComponents is like JSONSchema, but returns a JSON object mapping
+// names to the schemas of the specified named structure types, or pointers to
+// named structure types, and of all named structure types they refer to. The
+// schemas refer to each other by refPrefix followed by the name. For example,
+// with a refPrefix of "#/components/schemas/", the result can be used as the
+// schemas section of the components of an OpenAPI 3.1 document. Names are the
+// type names, qualified with the package name if necessary to make them
+// unique.
+func (t *T) JSONSchemaComponents(
+	refPrefix string, types ...reflect.Type,
+) ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	g := t.newSchemaGenerator(refPrefix)
+	for _, typ := range types {
+		if typ == nil {
+			return nil, errors.New("type is nil")
+		}
+		structType, _ := getStructType(typ)
+		if structType == nil || structType.Name() == "" {
+			return nil, fmt.Errorf("%s: not a named structure type", typ)
+		}
+		t.useRoot("", structType)
+		if _, err := g.schema("", structType); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(g.defs)
+}
+
+// schemaGenerator holds the state of a single JSON Schema generation.
+type schemaGenerator struct {
+	// t is the structure filter.
+	t *T
+
+	// ctx is the context for mapping types.
+	ctx context.Context
+
+	// refPrefix is the prefix of references to definitions.
+	refPrefix string
+
+	// defs maps definition names to the schemas of named structure types.
+	defs map[string]interface{}
+
+	// names maps named structure types to their definition names.
+	names map[schemaKey]string
+
+	// active holds the named types of kinds other than structure currently
+	// being described.
+	active map[reflect.Type]bool
+}
+
+// schemaKey identifies the definition of a named structure type. A structure
+// type whose filtered type depends on the path may have several definitions,
+// see Field.Path.
+type schemaKey struct {
+	// orig is the original structure type.
+	orig reflect.Type
+
+	// filtered is the filtered structure type.
+	filtered reflect.Type
+}
+
+// newSchemaGenerator creates a new schema generator with the specified
+// reference prefix.
+func (t *T) newSchemaGenerator(refPrefix string) *schemaGenerator {
+	return &schemaGenerator{
+		t:         t,
+		ctx:       context.Background(),
+		refPrefix: refPrefix,
+		defs:      make(map[string]interface{}),
+		names:     make(map[schemaKey]string),
+		active:    make(map[reflect.Type]bool),
+	}
+}
+
+// schema returns the schema for the converted values of the original type
+// orig at the specified path, see Field.Path.
+func (g *schemaGenerator) schema(
+	path string, orig reflect.Type,
+) (map[string]interface{}, error) {
+	filtered, err := g.t.mapType(g.ctx, path, orig)
+	if errors.Is(err, errDrop) {
+		return map[string]interface{}{"type": "null"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if g.t.typeAction(orig) == ActionRedact {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+	if isNamedContainer(orig) {
+		if g.active[orig] {
+			return map[string]interface{}{}, nil // recursive
+		}
+		g.active[orig] = true
+		defer delete(g.active, orig)
+	}
+	if filtered == orig && orig.Kind() != reflect.Interface {
+		// Methods are retained only if the type has not been altered.
+		switch {
+		case orig.Implements(jsonMarshalerType):
+			return map[string]interface{}{}, nil
+		case orig.Implements(textMarshalerType):
+			return map[string]interface{}{"type": "string"}, nil
+		}
+	}
+	switch orig.Kind() {
+	case reflect.Array:
+		if filtered.Len() != orig.Len() {
+			return map[string]interface{}{"type": "array", "maxItems": 0}, nil
+		}
+		items, err := g.schema(path, orig.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    items,
+			"minItems": orig.Len(),
+			"maxItems": orig.Len(),
+		}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Map:
+		if g.isRemoved(orig.Key()) || g.isRemoved(orig.Elem()) {
+			return map[string]interface{}{
+				"type":          []string{"object", "null"},
+				"maxProperties": 0,
+			}, nil
+		}
+		switch orig.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16,
+			reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+			reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !orig.Key().Implements(textMarshalerType) {
+				return nil, fmt.Errorf("unsupported map key type %s", orig.Key())
+			}
+		}
+		values, err := g.schema(path, orig.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": values,
+		}, nil
+	case reflect.Ptr:
+		elem, err := g.schema(path, orig.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(elem), nil
+	case reflect.Slice:
+		if g.isRemoved(orig.Elem()) {
+			return map[string]interface{}{
+				"type":     []string{"array", "null"},
+				"maxItems": 0,
+			}, nil
+		}
+		if filtered.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{
+				"type":            []string{"string", "null"},
+				"contentEncoding": "base64",
+			}, nil
+		}
+		items, err := g.schema(path, orig.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": items,
+		}, nil
+	case reflect.Struct:
+		info := g.t.filteredInfo("", path, orig, filtered)
+		if info == nil {
+			return nil, errors.New("filtered type not cached")
+		}
+		if orig.Name() == "" {
+			return g.structSchema(info)
+		}
+		name, err := g.define(orig, info)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"$ref": g.refPrefix + name}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if filtered.Kind() == reflect.String { // NonDataDescribe
+			return map[string]interface{}{"type": "string"}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", orig)
+}
+
+// isRemoved reports whether values of the original type orig are removed
+// from arrays, maps, and slices.
+func (g *schemaGenerator) isRemoved(orig reflect.Type) bool {
+	_, err := g.t.mapType(g.ctx, "", orig)
+	return errors.Is(err, errRemoved)
+}
+
+// define adds the schema for the named structure type orig, filtered as
+// described by info, to the definitions, unless already present, and returns
+// its definition name.
+func (g *schemaGenerator) define(
+	orig reflect.Type, info *typeInfo,
+) (string, error) {
+	key := schemaKey{orig: orig, filtered: info.filtered}
+	if name, ok := g.names[key]; ok {
+		return name, nil
+	}
+	name := orig.Name()
+	if _, taken := g.defs[name]; taken {
+		name = schemaName(orig.String())
+	}
+	for i := 2; ; i++ {
+		if _, taken := g.defs[name]; !taken {
+			break
+		}
+		name = schemaName(orig.String()) + "_" + strconv.Itoa(i)
+	}
+	g.names[key] = name
+	g.defs[name] = nil // reserve our spot
+	schema, err := g.structSchema(info)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", orig, err)
+	}
+	g.defs[name] = schema
+	return name, nil
+}
+
+// structSchema returns the schema for the converted values of the original
+// structure type filtered as described by info.
+func (g *schemaGenerator) structSchema(
+	info *typeInfo,
+) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	required := []string{}
+	if err := g.addProperties(
+		info, properties, &required, true, map[reflect.Type]bool{},
+	); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+// addProperties adds the properties for the fields of the filtered structure
+// type described by info to properties, and the names of the required
+// properties to required, unless properties already holds a property of the
+// same name. Fields of embedded structures are promoted like the
+// encoding/json package does, but with lower precedence than all other
+// fields. The fields of embedded structures reached through pointers are
+// never required. seen holds the embedded types already visited.
+func (g *schemaGenerator) addProperties(
+	info *typeInfo, properties map[string]interface{}, required *[]string,
+	mandatory bool, seen map[reflect.Type]bool,
+) error {
+	type embedded struct {
+		typ       reflect.Type
+		path      string
+		mandatory bool
+	}
+	var embeddedTypes []embedded
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if !fi.field.keep {
+			continue
+		}
+		name, opts, ok := jsonField(fi.filtered)
+		if !ok {
+			continue
+		}
+		fieldType, indirect := fi.orig.Type, false
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType, indirect = fieldType.Elem(), true
+		}
+		tagName := strings.SplitN(fi.filtered.Tag.Get("json"), ",", 2)[0]
+		if fi.filtered.Anonymous && !fi.field.redact && tagName == "" &&
+			fieldType.Kind() == reflect.Struct {
+			if !seen[fieldType] {
+				seen[fieldType] = true
+				embeddedTypes = append(embeddedTypes, embedded{
+					typ:       fieldType,
+					path:      fi.field.path,
+					mandatory: mandatory && !indirect,
+				})
+			}
+			continue
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		var schema map[string]interface{}
+		switch {
+		case fi.field.redact:
+			schema = map[string]interface{}{"type": "string"}
+		case hasJSONOption(opts, "string") && isQuotable(fieldType):
+			schema = map[string]interface{}{"type": "string"}
+			if indirect {
+				schema = nullable(schema)
+			}
+		default:
+			var err error
+			if schema, err = g.schema(fi.field.path, fi.orig.Type); err != nil {
+				return fmt.Errorf("%s: %w", fi.orig.Name, err)
+			}
+		}
+		properties[name] = schema
+		if mandatory && (fi.field.redact || !hasJSONOption(opts, "omitempty")) {
+			*required = append(*required, name)
+		}
+	}
+	for _, e := range embeddedTypes {
+		filtered, err := g.t.mapType(g.ctx, e.path, e.typ)
+		if err != nil {
+			return err
+		}
+		embeddedInfo := g.t.filteredInfo("", e.path, e.typ, filtered)
+		if embeddedInfo == nil {
+			return errors.New("filtered type not cached")
+		}
+		if err := g.addProperties(
+			embeddedInfo, properties, required, e.mandatory, seen,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isQuotable reports whether the string option of json tags applies to
+// values of typ.
+func isQuotable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// nullable returns a schema which allows null in addition to the values
+// allowed by schema.
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if len(schema) == 0 {
+		return schema // anything goes
+	}
+	switch typ := schema["type"].(type) {
+	case string:
+		if typ == "null" {
+			return schema
+		}
+		result := make(map[string]interface{}, len(schema))
+		for key, value := range schema {
+			result[key] = value
+		}
+		result["type"] = []string{typ, "null"}
+		return result
+	case []string:
+		return schema // already nullable
+	}
+	return map[string]interface{}{
+		"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}},
+	}
+}
+
+// schemaName returns s with all characters not allowed in the names of
+// OpenAPI components replaced with underscores.
+func schemaName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,210 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// SchemaUser is a structure type for testing JSON Schema generation.
+type SchemaUser struct {
+	ID       int64          `json:"id,string"`
+	Name     string         `json:"name"`
+	Email    string         `json:"email,omitempty"`
+	Password string         `json:"-"`
+	Token    string         // redacted by filter
+	Key      Secret         `json:"key"`
+	Avatar   []byte         `json:"avatar,omitempty"`
+	Manager  *SchemaUser    `json:"manager,omitempty"`
+	Tags     map[string]int `json:"tags"`
+	Extra    interface{}    `json:"extra"`
+	SchemaAudit
+	private int
+}
+
+// SchemaAudit is a structure type for testing JSON Schema generation.
+type SchemaAudit struct {
+	Created uint `json:"created"`
+	Name    string
+}
+
+// schemaUserDef is the expected schema definition for SchemaUser.
+const schemaUserDef = `{
+	"type": "object",
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string"},
+		"name": {"type": "string"},
+		"email": {"type": "string"},
+		"Token": {"type": "string"},
+		"key": {"type": "string"},
+		"avatar": {"type": ["string", "null"], "contentEncoding": "base64"},
+		"manager": {"anyOf": [{"$ref": "%sSchemaUser"}, {"type": "null"}]},
+		"tags": {
+			"type": ["object", "null"],
+			"additionalProperties": {"type": "integer"}
+		},
+		"extra": {},
+		"created": {"type": "integer", "minimum": 0},
+		"Name": {"type": "string"}
+	},
+	"required": ["id", "name", "Token", "key", "tags", "extra", "created",
+		"Name"]
+}`
+
+// newSchemaFilter returns a filter for testing JSON Schema generation.
+func newSchemaFilter() *T {
+	return New(When(NameMatches(Exact("Token")), redactFilter))
+}
+
+// TestJSONSchema tests generating a JSON Schema for a recursive type.
+func TestJSONSchema(t *testing.T) {
+	for _, recursion := range []Recursion{
+		RecursionInterface, RecursionJSON, RecursionMap,
+	} {
+		filter := newSchemaFilter()
+		filter.SetRecursion(recursion)
+		schema, err := filter.JSONSchema(reflect.TypeOf(SchemaUser{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"$ref": "#/$defs/SchemaUser",
+			"$defs": {"SchemaUser": ` +
+			strings.Replace(schemaUserDef, "%s", "#/$defs/", 1) + `}
+		}`
+		if !jsonEqual(t, schema, []byte(expected)) {
+			t.Errorf("Strategy %d: unexpected schema %s", recursion, schema)
+		}
+	}
+}
+
+// TestJSONSchemaOutput tests that converted values have the properties
+// described by the schema.
+func TestJSONSchemaOutput(t *testing.T) {
+	filter := newSchemaFilter()
+	schema, err := filter.JSONSchema(reflect.TypeOf([]*SchemaUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Type  []string `json:"type"`
+		Items struct {
+			AnyOf []map[string]string `json:"anyOf"`
+		} `json:"items"`
+		Defs map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+			Required   []string               `json:"required"`
+		} `json:"$defs"`
+	}
+	if err = json.Unmarshal(schema, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Items.AnyOf) != 2 ||
+		doc.Items.AnyOf[0]["$ref"] != "#/$defs/SchemaUser" {
+		t.Fatalf("Unexpected schema %s", schema)
+	}
+	def := doc.Defs["SchemaUser"]
+	users := []*SchemaUser{{
+		ID:          1,
+		Name:        "alice",
+		Manager:     &SchemaUser{Email: "bob@example.com"},
+		SchemaAudit: SchemaAudit{Name: "audit"},
+	}}
+	data := convertJSON(t, filter, users)
+	var objects []map[string]interface{}
+	if err = json.Unmarshal([]byte(data), &objects); err != nil {
+		t.Fatal(err)
+	}
+	object := objects[0]
+	manager := object["manager"].(map[string]interface{})
+	for _, obj := range []map[string]interface{}{object, manager} {
+		for key := range obj {
+			if _, ok := def.Properties[key]; !ok {
+				t.Errorf("Property %s not in schema", key)
+			}
+		}
+		for _, key := range def.Required {
+			if _, ok := obj[key]; !ok {
+				t.Errorf("Required property %s missing from %s", key, data)
+			}
+		}
+	}
+}
+
+// TestJSONSchemaComponents tests generating OpenAPI components.
+func TestJSONSchemaComponents(t *testing.T) {
+	filter := newSchemaFilter()
+	prefix := "#/components/schemas/"
+	components, err := filter.JSONSchemaComponents(
+		prefix, reflect.TypeOf(&SchemaUser{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"SchemaUser": ` +
+		strings.Replace(schemaUserDef, "%s", prefix, 1) + `}`
+	if !jsonEqual(t, components, []byte(expected)) {
+		t.Errorf("Unexpected components %s", components)
+	}
+	if _, err = filter.JSONSchemaComponents(
+		prefix, reflect.TypeOf([]SchemaUser{}),
+	); err == nil {
+		t.Error("Expected error for slice type")
+	}
+}
+
+// TestJSONSchemaTypes tests the schemas for various types.
+func TestJSONSchemaTypes(t *testing.T) {
+	filter := New()
+	filter.SetTypeFilters(TypeFilter(ActionRemove, reflect.TypeOf(Token{})))
+	for _, c := range []struct {
+		v        interface{}
+		expected string
+	}{
+		{[3]float64{}, `{"type": "array", "items": {"type": "number"},
+			"minItems": 3, "maxItems": 3}`},
+		{map[int]bool{}, `{"type": ["object", "null"],
+			"additionalProperties": {"type": "boolean"}}`},
+		{[]Token{}, `{"type": ["array", "null"], "maxItems": 0}`},
+		{Token{}, `{"type": "null"}`},
+		{struct {
+			A *int `json:"a,string,omitempty"`
+		}{}, `{"type": "object", "additionalProperties": false,
+			"properties": {"a": {"type": ["string", "null"]}},
+			"required": []}`},
+		{json.RawMessage{}, `{}`},
+	} {
+		schema, err := filter.JSONSchema(reflect.TypeOf(c.v))
+		if err != nil {
+			t.Errorf("Error for %T: %s", c.v, err)
+			continue
+		}
+		var doc map[string]interface{}
+		if err = json.Unmarshal(schema, &doc); err != nil {
+			t.Fatal(err)
+		}
+		delete(doc, "$schema")
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(t, data, []byte(c.expected)) {
+			t.Errorf("Unexpected schema for %T: %s", c.v, data)
+		}
+	}
+	for _, v := range []interface{}{
+		complex(1, 2), make(chan int), map[[2]int]int{},
+	} {
+		if _, err := filter.JSONSchema(reflect.TypeOf(v)); err == nil {
+			t.Errorf("Expected error for %T", v)
+		}
+	}
+	filter.SetNonData(NonDataDescribe)
+	schema, err := filter.JSONSchema(reflect.TypeOf(make(chan int)))
+	if err != nil || !strings.Contains(string(schema), `"type":"string"`) {
+		t.Errorf("Unexpected schema for described channel: %s/%v", schema, err)
+	}
+}
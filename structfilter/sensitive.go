@@ -0,0 +1,69 @@
+package structfilter
+
+import (
+	"reflect"
+)
+
+// Sensitive is the interface implemented by types whose values are
+// sensitive. Unless registered otherwise with T.RegisterSensitive, a type
+// whose Sensitive method reports true is treated as registered with
+// ActionRedact. The method is called once per type, on the zero value of the
+// type, so it must not depend on the value.
+type Sensitive interface {
+	Sensitive() bool
+}
+
+// Secret is a string type whose values are sensitive. Declaring a field as a
+// Secret, rather than as a string, makes sure the field is redacted wherever
+// it appears.
+type Secret string
+
+// Sensitive implements the Sensitive interface.
+func (Secret) Sensitive() bool {
+	return true
+}
+
+// sensitiveType is the reflect type of the Sensitive interface.
+var sensitiveType = reflect.TypeOf(new(Sensitive)).Elem()
+
+// RegisterSensitive declares the specified original type as sensitive.
+// Sensitive types are treated according to action wherever they appear, see
+// TypeFunc. A registration takes precedence over type functions set with
+// T.SetTypeFilters. ActionKeep undoes a registration, or exempts a type
+// implementing Sensitive.
+//
+// Registering a type discards all filtered types created so far.
+func (t *T) RegisterSensitive(typ reflect.Type, action Action) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.sensitive == nil {
+		t.sensitive = make(map[reflect.Type]Action)
+	}
+	t.sensitive[typ] = action
+	t.resetTypes()
+}
+
+// isSensitive calls the Sensitive method on the zero value of typ, which must
+// implement Sensitive. If the method panics, typ is considered sensitive.
+func isSensitive(typ reflect.Type) (sensitive bool) {
+	defer func() {
+		if recover() != nil {
+			sensitive = true
+		}
+	}()
+	if typ.Kind() == reflect.Interface {
+		return true // no method to call on nil
+	}
+	return reflect.Zero(typ).Interface().(Sensitive).Sensitive()
+}
+
+// isNilValue reports whether value is of a kind which can be nil and is nil.
+func isNilValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map,
+		reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
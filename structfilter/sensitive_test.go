@@ -0,0 +1,146 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Password is a sensitive string type for testing.
+type Password string
+
+// APIKey is a structure type for testing sensitive structure types.
+type APIKey struct {
+	ID     string
+	Secret string
+}
+
+// Vault is a structure type for testing sensitive types.
+type Vault struct {
+	Owner     string
+	Master    Secret
+	Passwords []Password
+	ByName    map[string]*Password
+	Keys      []APIKey
+	Key       *APIKey `json:",omitempty"`
+	Any       interface{}
+	NoKey     *APIKey
+}
+
+// TestSensitive tests sensitive types.
+func TestSensitive(t *testing.T) {
+	filter := New()
+	filter.RegisterSensitive(reflect.TypeOf(Password("")), ActionRedact)
+	filter.RegisterSensitive(reflect.TypeOf(APIKey{}), ActionRedact)
+	pw := Password("hunter2")
+	vault := Vault{
+		Owner:     "alice",
+		Master:    "open sesame",
+		Passwords: []Password{"a", "b"},
+		ByName:    map[string]*Password{"x": &pw, "y": nil},
+		Keys:      []APIKey{{ID: "1", Secret: "s1"}},
+		Key:       &APIKey{ID: "2", Secret: "s2"},
+		Any:       []interface{}{pw, APIKey{}, Secret("s")},
+	}
+	converted, err := filter.Convert(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Owner":"alice","Master":"[REDACTED]",` +
+		`"Passwords":["[REDACTED]","[REDACTED]"],` +
+		`"ByName":{"x":"[REDACTED]","y":null},"Keys":["[REDACTED]"],` +
+		`"Key":"[REDACTED]","Any":["[REDACTED]","[REDACTED]","[REDACTED]"],` +
+		`"NoKey":null}`
+	if !jsonEqual(t, data, []byte(expected)) {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	encoded, err := filter.EncodeJSON(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != string(data) {
+		t.Errorf("EncodeJSON: expected %s, got %s", data, encoded)
+	}
+	// Shallow copies must not bypass sensitive types.
+	filter.RegisterSensitive(reflect.TypeOf(""), ActionRedact)
+	converted, err = filter.Convert([]string{"plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := converted.([]string)[0]; s != Redacted {
+		t.Errorf("Expected redacted string, got %s", s)
+	}
+}
+
+// TestSensitiveRemove tests sensitive types registered with ActionRemove.
+func TestSensitiveRemove(t *testing.T) {
+	filter := New()
+	filter.RegisterSensitive(reflect.TypeOf(APIKey{}), ActionRemove)
+	filter.RegisterSensitive(reflect.TypeOf(Secret("")), ActionKeep)
+	converted, err := filter.Convert(Vault{
+		Master: "open sesame",
+		Keys:   []APIKey{{}},
+		Any:    &APIKey{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(converted)
+	for _, name := range []string{"Key", "NoKey"} {
+		if value.FieldByName(name).IsValid() {
+			t.Errorf("Expected field %s to be removed", name)
+		}
+	}
+	if keys := value.FieldByName("Keys"); keys.Len() != 0 {
+		t.Errorf("Expected no keys, got %v", keys)
+	}
+	if any := value.FieldByName("Any"); !any.IsNil() {
+		t.Errorf("Expected nil Any, got %v", any)
+	}
+	if master := value.FieldByName("Master"); master.String() != "open sesame" {
+		t.Errorf("Expected Master to be kept, got %v", master)
+	}
+	report, err := filter.Explain(reflect.TypeOf(Vault{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Structs[0].Fields[5].Sensitive ||
+		!strings.Contains(report.String(), "remove Key *structfilter.APIKey "+
+			"(default), sensitive type") {
+		t.Errorf("Unexpected report:\n%s", report)
+	}
+	filter.SetNonData(NonDataError)
+	if _, err = filter.Convert(struct{ C chan APIKey }{}); !errors.Is(
+		err, ErrNonData,
+	) {
+		t.Errorf("Expected ErrNonData, got %v", err)
+	}
+}
+
+// TestSensitiveSource tests rendering sensitive types as Go source.
+func TestSensitiveSource(t *testing.T) {
+	filter := New()
+	filter.RegisterSensitive(reflect.TypeOf(APIKey{}), ActionRedact)
+	src, err := filter.GoSource(reflect.TypeOf(Vault{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"\tMaster    string\n",
+		"\tKeys      []string\n",
+		"\tKey       *string `json:\",omitempty\"`\n",
+	} {
+		if !strings.Contains(src, line) {
+			t.Errorf("Expected %q in source:\n%s", line, src)
+		}
+	}
+	if strings.Contains(src, "APIKeyFiltered") {
+		t.Errorf("Unexpected declaration in source:\n%s", src)
+	}
+}
@@ -0,0 +1,186 @@
+package structfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// GoSource renders the filtered type for the specified original type as Go
+// source code. Every named structure type reachable from orig is rendered as
+// a named type declaration, with the original name suffixed by "Filtered".
+// Unnamed structure types are rendered inline. Fields where recursion has
+// been cut are marked with a comment, see Recursion.
+//
+// The generated types themselves are unnamed, so the output is meant for
+// human consumption, e. g., for debugging or reviewing a filter. orig can be
+// any type Convert accepts values of. If values of orig are dropped
+// altogether, see NonDataDrop and TypeFunc, the output declares Filtered as
+// an empty interface type, marked with a comment, as Convert returns nil for
+// such values.
+func (t *T) GoSource(orig reflect.Type) (string, error) {
+	if orig == nil {
+		return "", errors.New("orig is nil")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	filtered, err := t.mapType(context.Background(), "", orig)
+	if errors.Is(err, errDrop) {
+		return "type Filtered interface{} // dropped\n", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	r := sourceRenderer{
+		t:     t,
+		names: make(map[*typeInfo]string),
+		used:  make(map[string]bool),
+	}
+	info := t.filteredInfo("", "", orig, filtered)
+	if orig.Kind() != reflect.Struct || orig.Name() == "" || info == nil {
+		fmt.Fprintf(&r.sb, "type Filtered %s\n",
+			r.typeExpr(orig, filtered, "", ""))
+	} else {
+		r.declName(orig, info)
+	}
+	for len(r.queue) != 0 {
+		next := r.queue[0]
+		r.queue = r.queue[1:]
+		if r.sb.Len() != 0 {
+			r.sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&r.sb, "type %s %s\n", r.names[next],
+			r.structExpr(next, ""))
+	}
+	src, err := format.Source([]byte(r.sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("format generated source: %w", err)
+	}
+	return string(src), nil
+}
+
+// sourceRenderer keeps track of the state of T.GoSource.
+type sourceRenderer struct {
+	// t is the structure filter whose types are rendered.
+	t *T
+
+	// sb receives the rendered declarations.
+	sb strings.Builder
+
+	// names maps filtered named structure types to their declaration names.
+	// A structure type whose filtered type depends on the path may have
+	// several declarations, see Field.Path.
+	names map[*typeInfo]string
+
+	// used records the declaration names in use.
+	used map[string]bool
+
+	// queue holds filtered named structure types yet to be declared.
+	queue []*typeInfo
+}
+
+// declName returns the declaration name for the filtered type info of the
+// named original structure type orig. If info has not been seen before, it is
+// queued for declaration.
+func (r *sourceRenderer) declName(orig reflect.Type, info *typeInfo) string {
+	if name, ok := r.names[info]; ok {
+		return name
+	}
+	base := orig.Name() + "Filtered"
+	name := base
+	for i := 2; r.used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	r.names[info] = name
+	r.used[name] = true
+	r.queue = append(r.queue, info)
+	return name
+}
+
+// typeExpr renders the filtered type for orig as a Go type expression.
+// filtered is the filtered type for orig, and path is the path of orig, see
+// Field.Path. indent is the indentation of the line the expression starts on.
+func (r *sourceRenderer) typeExpr(
+	orig, filtered reflect.Type, path, indent string,
+) string {
+	if filtered == orig || isCutType(filtered) ||
+		filtered.Kind() != orig.Kind() {
+		return filtered.String()
+	}
+	switch orig.Kind() {
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", filtered.Len(),
+			r.typeExpr(orig.Elem(), filtered.Elem(), path, indent))
+	case reflect.Interface:
+		return "interface{}"
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s",
+			r.typeExpr(orig.Key(), filtered.Key(), path, indent),
+			r.typeExpr(orig.Elem(), filtered.Elem(), path, indent))
+	case reflect.Ptr:
+		return "*" + r.typeExpr(orig.Elem(), filtered.Elem(), path, indent)
+	case reflect.Slice:
+		return "[]" + r.typeExpr(orig.Elem(), filtered.Elem(), path, indent)
+	case reflect.Struct:
+		info := r.t.filteredInfo("", path, orig, filtered)
+		if info == nil {
+			return filtered.String()
+		}
+		if orig.Name() != "" {
+			return r.declName(orig, info)
+		}
+		return r.structExpr(info, indent)
+	default:
+		return filtered.String()
+	}
+}
+
+// structExpr renders the filtered structure type described by info as a
+// struct type literal.
+func (r *sourceRenderer) structExpr(info *typeInfo, indent string) string {
+	var sb strings.Builder
+	sb.WriteString("struct {\n")
+	fieldIndent := indent + "\t"
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if !fi.field.keep {
+			continue
+		}
+		sb.WriteString(fieldIndent)
+		sb.WriteString(fi.filtered.Name)
+		sb.WriteByte(' ')
+		if fi.field.redact {
+			sb.WriteString(fi.filtered.Type.String())
+		} else {
+			sb.WriteString(r.typeExpr(fi.orig.Type, fi.filtered.Type,
+				fi.field.path, fieldIndent))
+		}
+		if fi.filtered.Tag != "" {
+			sb.WriteByte(' ')
+			sb.WriteString(quoteTag(fi.filtered.Tag))
+		}
+		switch {
+		case fi.field.redact:
+			sb.WriteString(" // redacted")
+		case fi.cut:
+			fmt.Fprintf(&sb, " // recursive: %s", fi.orig.Type)
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(indent)
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// quoteTag renders tag as a Go string literal, preferring a raw string
+// literal.
+func quoteTag(tag reflect.StructTag) string {
+	if strconv.CanBackquote(string(tag)) {
+		return "`" + string(tag) + "`"
+	}
+	return strconv.Quote(string(tag))
+}
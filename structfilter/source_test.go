@@ -0,0 +1,88 @@
+package structfilter
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestGoSource tests the GoSource method.
+func TestGoSource(t *testing.T) {
+	filter := New(
+		RemoveFieldFilter(regexp.MustCompile("^Remove.*$")),
+		InsertTagFilter(regexp.MustCompile("^Keep1$"), `json:"keep1"`),
+	)
+	src, err := filter.GoSource(reflect.TypeOf([]*StructKeepRemove{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "type Filtered []*StructKeepRemoveFiltered\n\n" +
+		"type StructKeepRemoveFiltered struct {\n" +
+		"\tKeep1 int `json:\"keep1\"`\n" +
+		"\tKeep2 int\n" +
+		"}\n"
+	if src != expected {
+		t.Errorf("Unexpected source:\n%s", src)
+	}
+}
+
+// TestGoSourceNested tests the GoSource method with nested and recursive
+// structures.
+func TestGoSourceNested(t *testing.T) {
+	filter := New()
+	type withAnonymous struct {
+		Nested NestedStruct
+		Anon   struct {
+			Rec *RecursiveStruct
+		}
+	}
+	src, err := filter.GoSource(reflect.TypeOf(withAnonymous{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"type withAnonymousFiltered struct {",
+		"Nested NestedStructFiltered",
+		"Rec *RecursiveStructFiltered",
+		"type NestedStructFiltered struct {",
+		"Map   map[nestedFiltered]nestedFiltered",
+		"type RecursiveStructFiltered struct {",
+		"Array [42]structfilter.Recursive // recursive: [42]*structfilter.RecursiveStruct",
+		"Ptr   structfilter.Recursive     // recursive: *structfilter.RecursiveStruct",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected '%s' in source:\n%s", expected, src)
+		}
+	}
+}
+
+// TestGoSourceSensitive tests the GoSource method with a redacted structure
+// type.
+func TestGoSourceSensitive(t *testing.T) {
+	filter := New()
+	filter.RegisterSensitive(reflect.TypeOf(NestedStruct{}), ActionRedact)
+	src, err := filter.GoSource(reflect.TypeOf(NestedStruct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "type Filtered string\n"; src != expected {
+		t.Errorf("Expected source '%s', got '%s'", expected, src)
+	}
+}
+
+// TestGoSourceRemovedArray tests the GoSource method with an array whose
+// element type is removed.
+func TestGoSourceRemovedArray(t *testing.T) {
+	filter := New()
+	filter.SetTypeFilters(TypeFilter(ActionRemove, reflect.TypeOf(Token{})))
+	src, err := filter.GoSource(reflect.TypeOf(struct {
+		Tokens [3]Token
+	}{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Tokens [0]interface{}"; !strings.Contains(src, expected) {
+		t.Errorf("Expected '%s' in source:\n%s", expected, src)
+	}
+}
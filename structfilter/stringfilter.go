@@ -0,0 +1,34 @@
+package structfilter
+
+import (
+	"context"
+)
+
+// StringFunc is a function type for transforming string values during
+// conversion, e. g., to mask personal data found in free text. ctx is the
+// context of the conversion, see T.ConvertContext. Whenever a StringFunc
+// returns a non-nil error, the conversion fails with that error.
+type StringFunc func(ctx context.Context, s string) (string, error)
+
+// SetStringFilters sets the string functions. During conversion, every value
+// of string kind, including map keys and strings found in interfaces, is
+// passed through the string functions in order. Note that map keys mapped to
+// the same string collapse into a single entry. T.FilterJSON passes string
+// values, but not member names, through the string functions as well.
+func (t *T) SetStringFilters(filters ...StringFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(filters) == 0 {
+		t.stringFilter = nil
+		return
+	}
+	t.stringFilter = func(ctx context.Context, s string) (string, error) {
+		var err error
+		for _, filter := range filters {
+			if s, err = filter(ctx, s); err != nil {
+				return "", err
+			}
+		}
+		return s, nil
+	}
+}
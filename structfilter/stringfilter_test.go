@@ -0,0 +1,81 @@
+package structfilter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// StringFilterStruct is a structure type for testing string functions.
+type StringFilterStruct struct {
+	Name  string
+	Notes []string
+	Extra map[string]interface{}
+}
+
+// upperFilter is a string function converting strings to upper case.
+func upperFilter(ctx context.Context, s string) (string, error) {
+	return strings.ToUpper(s), nil
+}
+
+// TestStringFilters tests string functions.
+func TestStringFilters(t *testing.T) {
+	filter := New()
+	type key struct{}
+	filter.SetStringFilters(upperFilter,
+		func(ctx context.Context, s string) (string, error) {
+			suffix, _ := ctx.Value(key{}).(string)
+			return s + suffix, nil
+		})
+	ctx := context.WithValue(context.Background(), key{}, "!")
+	converted, err := filter.ConvertContext(ctx, StringFilterStruct{
+		Name:  "joe",
+		Notes: []string{"a", "b"},
+		Extra: map[string]interface{}{"k": "v"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := StringFilterStruct{
+		Name:  "JOE!",
+		Notes: []string{"A!", "B!"},
+		Extra: map[string]interface{}{"K!": "V!"},
+	}
+	if s1, s2 := fmt.Sprintf("%+v", converted),
+		fmt.Sprintf("%+v", expected); s1 != s2 {
+		t.Errorf("Expected %s, got %s", s2, s1)
+	}
+	// Shallow copies must not bypass string functions.
+	converted, err = filter.Convert([]string{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := converted.([]string)[0]; s != "X" {
+		t.Errorf("Expected X, got %s", s)
+	}
+	var out bytes.Buffer
+	if err = filter.FilterJSON(
+		strings.NewReader(`{"name":"joe","n":1}`), &out,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != `{"name":"JOE","n":1}`+"\n" {
+		t.Errorf("Unexpected JSON %s", out.String())
+	}
+}
+
+// TestStringFilterError tests string functions returning errors.
+func TestStringFilterError(t *testing.T) {
+	filter := New()
+	filter.SetStringFilters(func(context.Context, string) (string, error) {
+		return "", errFilter
+	})
+	if _, err := filter.Convert(StringFilterStruct{}); !errors.Is(
+		err, errFilter,
+	) {
+		t.Errorf("Expected errFilter, got %v", err)
+	}
+}
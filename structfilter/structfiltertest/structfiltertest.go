@@ -0,0 +1,417 @@
+// Package structfiltertest provides utilities for testing structure filters
+// from the structfilter package for leaks.
+//
+// A leak test fills a value with canaries, i. e., unique random strings and
+// numbers, one per field, runs the value through a structure filter,
+// serialises the result, and looks for canaries in the output:
+//
+//     func TestUserFilter(t *testing.T) {
+//         structfiltertest.NoLeaks(t, filter, new(User), "Name", "Email")
+//     }
+package structfiltertest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TheCount/go-structfilter/structfilter"
+)
+
+// Formats checked for canaries.
+const (
+	// FormatJSON is the output of json.Marshal.
+	FormatJSON = "json"
+
+	// FormatFmt is the output of fmt.Sprintf with the verb %+v.
+	FormatFmt = "%+v"
+
+	// FormatGob is the output of a gob.Encoder, checked both as is and after
+	// decoding it.
+	FormatGob = "gob"
+)
+
+// Canary is a canary value filled into a field.
+type Canary struct {
+	// Path is the path of the field in the original value. Field names are
+	// separated by dots. Slice and array elements are denoted by their index
+	// in brackets, and map keys and values by the suffixes "(key)" and
+	// "(value)", e. g., "Accounts[0].Labels(value)".
+	Path string
+
+	// Value is the canary value.
+	Value interface{}
+
+	// tokens are the representations of Value to look for in outputs.
+	tokens []string
+}
+
+// Leak describes a canary found in the output of a structure filter.
+type Leak struct {
+	Canary
+
+	// Format is the format of the output the canary was found in, e. g.,
+	// FormatJSON.
+	Format string
+}
+
+// String returns a description of the leak.
+func (l Leak) String() string {
+	return fmt.Sprintf("%s leaked in %s (%v)", l.Path, l.Format, l.Value)
+}
+
+// Report is the result of a leak test.
+type Report struct {
+	// Canaries are the canaries filled into the original value, ordered by
+	// path.
+	Canaries []Canary
+
+	// Leaks are the canaries found in outputs, ordered by path, and then in
+	// the order FormatJSON, FormatFmt, FormatGob.
+	Leaks []Leak
+
+	// Skipped maps the formats into which the converted value could not be
+	// serialised to the respective errors.
+	Skipped map[string]error
+}
+
+// Leaked returns the paths of all leaked canaries, without duplicates.
+func (r *Report) Leaked() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, leak := range r.Leaks {
+		if !seen[leak.Path] {
+			seen[leak.Path] = true
+			paths = append(paths, leak.Path)
+		}
+	}
+	return paths
+}
+
+// Fill fills the value ptr points to with canaries and returns them, ordered
+// by path. ptr must be a non-nil pointer.
+//
+// Fill fills all exported fields of string kind, or of a numeric kind wide
+// enough to hold distinctive numbers, i. e., int, int32, int64, uint, uint32,
+// uint64, float32, and float64. Byte slices are filled with a canary string,
+// which is also looked for in base64, as encoded by the encoding/json
+// package, and as a list of decimal bytes, as printed by the fmt package.
+// Structures, arrays, and pointers are followed. Slices get one element, and
+// maps one entry. Pointers to structure types already being filled, and
+// values of named array, map, pointer, and slice types already being filled,
+// are left alone, so that recursive types are filled to a finite depth.
+// Interfaces, booleans, and other kinds are left alone as well.
+func Fill(ptr interface{}) ([]Canary, error) {
+	value := reflect.ValueOf(ptr)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil, errors.New("not a non-nil pointer")
+	}
+	f := &filler{
+		active: make(map[reflect.Type]bool),
+		used:   make(map[string]bool),
+	}
+	if err := f.fill(value.Elem(), ""); err != nil {
+		return nil, err
+	}
+	sort.Slice(f.canaries, func(i, j int) bool {
+		return f.canaries[i].Path < f.canaries[j].Path
+	})
+	return f.canaries, nil
+}
+
+// filler keeps track of the state of Fill.
+type filler struct {
+	// canaries are the canaries filled in so far.
+	canaries []Canary
+
+	// active records the structure types, and named array, map, pointer, and
+	// slice types, currently being filled.
+	active map[reflect.Type]bool
+
+	// used records the tokens of the canaries filled in so far.
+	used map[string]bool
+}
+
+// fill fills value, which is at the specified path.
+func (f *filler) fill(value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Ptr, reflect.Slice:
+		if typ := value.Type(); typ.Name() != "" {
+			if f.active[typ] {
+				return nil
+			}
+			f.active[typ] = true
+			defer delete(f.active, typ)
+		}
+	}
+	switch value.Kind() {
+	case reflect.Array:
+		for i := 0; i != value.Len(); i++ {
+			if err := f.fill(
+				value.Index(i), fmt.Sprintf("%s[%d]", path, i),
+			); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(value.Type(), 1)
+		key := reflect.New(value.Type().Key()).Elem()
+		if err := f.fill(key, path+"(key)"); err != nil {
+			return err
+		}
+		elem := reflect.New(value.Type().Elem()).Elem()
+		if err := f.fill(elem, path+"(value)"); err != nil {
+			return err
+		}
+		m.SetMapIndex(key, elem)
+		value.Set(m)
+	case reflect.Ptr:
+		elemType := value.Type().Elem()
+		if f.active[elemType] {
+			return nil
+		}
+		elem := reflect.New(elemType)
+		if err := f.fill(elem.Elem(), path); err != nil {
+			return err
+		}
+		value.Set(elem)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			s, err := f.newString(path)
+			if err != nil {
+				return err
+			}
+			b := []byte(s)
+			value.Set(reflect.ValueOf(b).Convert(value.Type()))
+			f.add(path, value, s, base64.StdEncoding.EncodeToString(b),
+				fmt.Sprint(b))
+			return nil
+		}
+		slice := reflect.MakeSlice(value.Type(), 1, 1)
+		if err := f.fill(slice.Index(0), path+"[0]"); err != nil {
+			return err
+		}
+		value.Set(slice)
+	case reflect.String:
+		s, err := f.newString(path)
+		if err != nil {
+			return err
+		}
+		value.SetString(s)
+		f.add(path, value, s)
+	case reflect.Struct:
+		f.active[value.Type()] = true
+		defer delete(f.active, value.Type())
+		for i := 0; i != value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			if err := f.fill(value.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+	case reflect.Int, reflect.Int64:
+		n, err := f.newNumber(path, 100000000000, 900000000000)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+		f.add(path, value, strconv.FormatInt(n, 10))
+	case reflect.Int32:
+		n, err := f.newNumber(path, 100000000, 2000000000)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+		f.add(path, value, strconv.FormatInt(n, 10))
+	case reflect.Uint, reflect.Uint64:
+		n, err := f.newNumber(path, 100000000000, 900000000000)
+		if err != nil {
+			return err
+		}
+		value.SetUint(uint64(n))
+		f.add(path, value, strconv.FormatInt(n, 10))
+	case reflect.Uint32:
+		n, err := f.newNumber(path, 100000000, 4000000000)
+		if err != nil {
+			return err
+		}
+		value.SetUint(uint64(n))
+		f.add(path, value, strconv.FormatInt(n, 10))
+	case reflect.Float32:
+		// Integers up to 2^24 are exact in a float32.
+		n, err := f.newNumber(path, 1000000, 16000000)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(float64(n))
+		f.add(path, value, strconv.FormatInt(n, 10),
+			strconv.FormatFloat(float64(n), 'g', -1, 32))
+	case reflect.Float64:
+		n, err := f.newNumber(path, 100000000000, 900000000000)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(float64(n))
+		f.add(path, value, strconv.FormatInt(n, 10),
+			strconv.FormatFloat(float64(n), 'g', -1, 64))
+	}
+	return nil
+}
+
+// newString returns a new canary string for the specified path which is not
+// the token of a recorded canary yet.
+func (f *filler) newString(path string) (string, error) {
+	for {
+		var random [8]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		s := "canary" + hex.EncodeToString(random[:])
+		if !f.used[s] {
+			return s, nil
+		}
+	}
+}
+
+// newNumber returns a new random number in [min, max) which is not the token
+// of a recorded canary yet.
+func (f *filler) newNumber(path string, min, max int64) (int64, error) {
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(max-min))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		number := n.Int64() + min
+		if !f.used[strconv.FormatInt(number, 10)] {
+			return number, nil
+		}
+	}
+}
+
+// add records a canary with the specified value and tokens.
+func (f *filler) add(path string, value reflect.Value, tokens ...string) {
+	for _, token := range tokens {
+		f.used[token] = true
+	}
+	f.canaries = append(f.canaries, Canary{
+		Path:   path,
+		Value:  value.Interface(),
+		tokens: tokens,
+	})
+}
+
+// Check fills the value ptr points to with canaries, see Fill, converts ptr
+// with filter, and reports which canaries can be found in the JSON, %+v, and
+// gob serialisations of the converted value.
+func Check(filter *structfilter.T, ptr interface{}) (*Report, error) {
+	canaries, err := Fill(ptr)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := filter.Convert(ptr)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Canaries: canaries,
+		Skipped:  make(map[string]error),
+	}
+	outputs := map[string]string{
+		FormatFmt: fmt.Sprintf("%+v", converted),
+	}
+	if data, err := json.Marshal(converted); err != nil {
+		report.Skipped[FormatJSON] = err
+	} else {
+		outputs[FormatJSON] = string(data)
+	}
+	if output, err := gobOutput(converted); err != nil {
+		report.Skipped[FormatGob] = err
+	} else {
+		outputs[FormatGob] = output
+	}
+	for _, canary := range canaries {
+		for _, format := range []string{FormatJSON, FormatFmt, FormatGob} {
+			output, ok := outputs[format]
+			if !ok {
+				continue
+			}
+			for _, token := range canary.tokens {
+				if strings.Contains(output, token) {
+					report.Leaks = append(report.Leaks, Leak{
+						Canary: canary,
+						Format: format,
+					})
+					break
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// gobOutput returns the gob encoding of value, followed by the JSON encoding
+// of the decoded gob encoding, which reveals numbers.
+func gobOutput(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return "", err
+	}
+	output := buf.String()
+	decoded := reflect.New(reflect.TypeOf(value))
+	if err := gob.NewDecoder(&buf).DecodeValue(decoded); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(decoded.Interface())
+	if err != nil {
+		return "", err
+	}
+	return output + string(data), nil
+}
+
+// NoLeaks runs Check on filter and ptr, and reports an error to tb for every
+// canary found in an output whose path is not listed in allowed. It also
+// reports an error if Check fails, and for every format the converted value
+// could not be serialised into, as leaks in that format would go unnoticed.
+// Use Check directly to tolerate skipped formats.
+func NoLeaks(
+	tb testing.TB, filter *structfilter.T, ptr interface{}, allowed ...string,
+) *Report {
+	tb.Helper()
+	report, err := Check(filter, ptr)
+	if err != nil {
+		tb.Errorf("leak check: %s", err)
+		return nil
+	}
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, path := range allowed {
+		isAllowed[path] = true
+	}
+	for _, leak := range report.Leaks {
+		if !isAllowed[leak.Path] {
+			tb.Errorf("%s", leak)
+		}
+	}
+	for _, format := range []string{FormatJSON, FormatFmt, FormatGob} {
+		if err, ok := report.Skipped[format]; ok {
+			tb.Errorf("%s output skipped: %s", format, err)
+		}
+	}
+	return report
+}
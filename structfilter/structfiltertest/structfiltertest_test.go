@@ -0,0 +1,176 @@
+package structfiltertest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/TheCount/go-structfilter/structfilter"
+)
+
+// Credential is a structure type for testing.
+type Credential struct {
+	Password string
+	Pin      int32
+}
+
+// Account is a structure type for testing.
+type Account struct {
+	Name        string
+	Balance     float64
+	Credentials []Credential
+	Labels      map[string]string
+	Key         []byte
+	Parent      *Account
+	Active      bool
+	private     string
+}
+
+// TestFill tests filling values with canaries.
+func TestFill(t *testing.T) {
+	var account Account
+	canaries, err := Fill(&account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, canary := range canaries {
+		paths = append(paths, canary.Path)
+	}
+	expected := []string{
+		"Balance",
+		"Credentials[0].Password",
+		"Credentials[0].Pin",
+		"Key",
+		"Labels(key)",
+		"Labels(value)",
+		"Name",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+	if account.Parent != nil {
+		t.Error("Expected recursion to stop")
+	}
+	if account.Credentials[0].Password == "" || account.Name == "" ||
+		account.Name == account.Credentials[0].Password {
+		t.Error("Expected distinct canaries")
+	}
+	if _, err := Fill(account); err == nil {
+		t.Error("Expected error filling a non-pointer")
+	}
+}
+
+// TestCheck tests finding leaks.
+func TestCheck(t *testing.T) {
+	report, err := Check(structfilter.New(), new(Account))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Skipped) != 0 {
+		t.Errorf("Unexpected skipped formats: %v", report.Skipped)
+	}
+	leaked := report.Leaked()
+	if len(leaked) != len(report.Canaries) {
+		t.Errorf("Expected all canaries to leak, got %v", leaked)
+	}
+	found := make(map[string]bool)
+	for _, leak := range report.Leaks {
+		if leak.Path == "Credentials[0].Pin" {
+			found[leak.Format] = true
+		}
+	}
+	for _, format := range []string{FormatJSON, FormatFmt, FormatGob} {
+		if !found[format] {
+			t.Errorf("Expected PIN to leak in %s", format)
+		}
+	}
+	filter := structfilter.New(structfilter.RemoveFieldFilter(
+		regexp.MustCompile(`^(Password|Pin|Key|Labels)$`)))
+	report = NoLeaks(t, filter, new(Account), "Name", "Balance")
+	if len(report.Leaked()) != 2 {
+		t.Errorf("Expected Name and Balance to leak, got %v", report.Leaked())
+	}
+}
+
+// recorder is a testing.TB recording errors.
+type recorder struct {
+	testing.TB
+
+	// errors are the recorded errors.
+	errors []string
+}
+
+// Helper implements testing.TB.
+func (r *recorder) Helper() {}
+
+// Errorf implements testing.TB.
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// TestNoLeaks tests that NoLeaks reports leaks.
+func TestNoLeaks(t *testing.T) {
+	var r recorder
+	NoLeaks(&r, structfilter.New(), new(Credential), "Pin")
+	if len(r.errors) != 3 {
+		t.Errorf("Expected three leaks to be reported, got %v", r.errors)
+	}
+}
+
+// Session is a structure type with a byte slice for testing.
+type Session struct {
+	Token []byte
+}
+
+// TestCheckBytes tests finding leaked byte slices in all formats.
+func TestCheckBytes(t *testing.T) {
+	report, err := Check(structfilter.New(), new(Session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, leak := range report.Leaks {
+		found[leak.Format] = true
+	}
+	for _, format := range []string{FormatJSON, FormatFmt, FormatGob} {
+		if !found[format] {
+			t.Errorf("Expected token to leak in %s", format)
+		}
+	}
+}
+
+// Listener is a structure type which cannot be encoded as JSON.
+type Listener struct {
+	Name   string
+	Notify chan int
+}
+
+// TestNoLeaksSkipped tests that NoLeaks reports formats which could not be
+// checked.
+func TestNoLeaksSkipped(t *testing.T) {
+	var r recorder
+	NoLeaks(&r, structfilter.New(), new(Listener), "Name")
+	if len(r.errors) != 2 || !strings.HasPrefix(r.errors[0], FormatJSON) ||
+		!strings.HasPrefix(r.errors[1], FormatGob) {
+		t.Errorf("Expected skipped JSON and gob output to be reported, got %v",
+			r.errors)
+	}
+}
+
+// Tree is a recursive named map type for testing.
+type Tree map[string]Tree
+
+// TestFillNamed tests filling recursive named types other than structures.
+func TestFillNamed(t *testing.T) {
+	var tree Tree
+	canaries, err := Fill(&tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(canaries) != 1 || canaries[0].Path != "(key)" {
+		t.Errorf("Expected a single key canary, got %v", canaries)
+	}
+}
@@ -1,6 +1,7 @@
 package structfilter
 
 import (
+	"context"
 	"errors"
 	"reflect"
 )
@@ -28,7 +29,8 @@ func getStructType(t reflect.Type) (reflect.Type, int) {
 // ReflectType allows direct filtering of structure types as presented by the
 // golang reflect package. orig must be a structure type, or a pointer to a
 // a structure type. On success, the returned filtered
-// type is always a structure type, not a pointer type.
+// type is always a structure type, not a pointer type. For other types, use
+// MapType.
 func (t *T) ReflectType(orig reflect.Type) (reflect.Type, error) {
 	if orig == nil {
 		return nil, errors.New("orig is nil")
@@ -40,24 +42,88 @@ func (t *T) ReflectType(orig reflect.Type) (reflect.Type, error) {
 	if depth > 1 {
 		return nil, errors.New("at most one pointer indirection allowed")
 	}
-	if filteredType, ok := t.types[structType]; ok {
-		return filteredType, nil
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.useRoot("", orig)
+	if info, _ := t.cachedType("", "", structType); info != nil {
+		t.stats.Hits++
+		return info.filtered, nil
 	}
-	return t.filterType(structType)
+	return t.filterType(context.Background(), "", structType)
+}
+
+// MapType returns the filtered type for the specified original type, i. e.,
+// the type of the values Convert returns for values of type orig. MapType
+// accepts any type, including pointers to pointers and types which are not
+// structure types, e. g., []User or map[string]*User. If values of type orig
+// are dropped entirely, e. g., according to the non-data policy, Convert
+// returns nil for them, and MapType returns nil and no error.
+func (t *T) MapType(orig reflect.Type) (reflect.Type, error) {
+	return t.MapTypeContext(context.Background(), orig)
+}
+
+// MapTypeContext is like MapType, but passes ctx to the filter functions, see
+// Field.Context. If ctx carries a profile, see WithProfile, orig is mapped for
+// that profile.
+func (t *T) MapTypeContext(
+	ctx context.Context, orig reflect.Type,
+) (reflect.Type, error) {
+	if orig == nil {
+		return nil, errors.New("orig is nil")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.mapRootType(ctx, orig)
+}
+
+// mapRootType maps the original type orig passed to an exported method for
+// the profile of ctx, see T.MapTypeContext.
+func (t *T) mapRootType(
+	ctx context.Context, orig reflect.Type,
+) (reflect.Type, error) {
+	t.useRoot(profileFromContext(ctx), orig)
+	filtered, err := t.mapType(ctx, "", orig)
+	if errors.Is(err, errDrop) {
+		return nil, nil
+	}
+	return filtered, err
 }
 
 // mapType maps the specified original type to a matching generated type.
-// If orig cannot be mapped because it is recursive, nil is returned
-// instead.
-func (t *T) mapType(orig reflect.Type) (reflect.Type, error) {
+// If orig cannot be mapped because it refers to a structure type which is
+// still being filtered, nil is returned instead. The caller then cuts the
+// recursion, see Recursion. If orig has to be dropped according to the
+// non-data policy, or because of a type function, errDrop is returned.
+// Arrays, maps, and slices of types removed by type functions are mapped to
+// types without elements. Filter functions get to see ctx via
+// Field.Context, and path, the path of the field orig is the type of, via
+// Field.Path.
+func (t *T) mapType(
+	ctx context.Context, path string, orig reflect.Type,
+) (reflect.Type, error) {
+	if action := t.typeAction(orig); action != ActionKeep {
+		return mapTypeAction(action)
+	}
+	if isNamedContainer(orig) {
+		// Named types other than structure types can be recursive, too.
+		key := rootKey{profile: profileFromContext(ctx), typ: orig}
+		if t.pending[key] {
+			return nil, nil // recursive
+		}
+		t.pending[key] = true
+		defer delete(t.pending, key)
+	}
 	switch orig.Kind() {
 	case reflect.Array:
-		elem, err := t.mapType(orig.Elem())
+		elem, err := t.mapType(ctx, path, orig.Elem())
+		if errors.Is(err, errRemoved) {
+			return reflect.ArrayOf(0, interfaceType), nil
+		}
 		if err != nil {
 			return nil, err
 		}
 		if elem == nil {
-			return nil, nil
+			elem = t.cutType()
 		}
 		if elem == orig.Elem() {
 			return orig, nil
@@ -68,27 +134,41 @@ func (t *T) mapType(orig reflect.Type) (reflect.Type, error) {
 		// to plain interface{}.
 		return interfaceType, nil
 	case reflect.Map:
-		key, err := t.mapType(orig.Key())
-		if err != nil {
+		key, err := t.mapType(ctx, path, orig.Key())
+		removed := errors.Is(err, errRemoved)
+		if err != nil && !removed {
 			return nil, err
 		}
-		elem, err := t.mapType(orig.Elem())
-		if err != nil {
+		elem, err := t.mapType(ctx, path, orig.Elem())
+		if errors.Is(err, errRemoved) {
+			removed = true
+		} else if err != nil {
 			return nil, err
 		}
-		if key == nil || elem == nil {
+		if removed {
+			return reflect.MapOf(stringType, interfaceType), nil
+		}
+		if key == nil {
 			return nil, nil
 		}
+		if elem == nil {
+			elem = t.cutType()
+		}
 		if key == orig.Key() && elem == orig.Elem() {
 			return orig, nil
 		}
 		return reflect.MapOf(key, elem), nil
 	case reflect.Ptr:
-		elem, err := t.mapType(orig.Elem())
+		elem, err := t.mapType(ctx, path, orig.Elem())
 		if err != nil {
 			return nil, err
 		}
 		if elem == nil {
+			if isNamedContainer(orig.Elem()) {
+				// Cut here, as orig may be the outermost type, e. g.,
+				// type P *P.
+				return reflect.PtrTo(t.cutType()), nil
+			}
 			return nil, nil
 		}
 		if elem == orig.Elem() {
@@ -96,28 +176,51 @@ func (t *T) mapType(orig reflect.Type) (reflect.Type, error) {
 		}
 		return reflect.PtrTo(elem), nil
 	case reflect.Slice:
-		elem, err := t.mapType(orig.Elem())
+		elem, err := t.mapType(ctx, path, orig.Elem())
+		if errors.Is(err, errRemoved) {
+			return reflect.SliceOf(interfaceType), nil
+		}
 		if err != nil {
 			return nil, err
 		}
 		if elem == nil {
-			return nil, nil
+			elem = t.cutType()
 		}
 		if elem == orig.Elem() {
 			return orig, nil
 		}
 		return reflect.SliceOf(elem), nil
 	case reflect.Struct:
-		elem, ok := t.types[orig]
-		if ok {
-			return elem, nil // elem == nil if recursive
-		}
-		elem, err := t.filterType(orig)
+		profile := profileFromContext(ctx)
+		if t.pending[rootKey{profile: profile, typ: orig}] {
+			return nil, nil // recursive
+		}
+		if info, specific := t.cachedType(profile, path, orig); info != nil {
+			t.stats.Hits++
+			if specific {
+				t.pathDependent = true
+			}
+			return info.filtered, nil
+		}
+		elem, err := t.filterType(ctx, path, orig)
 		if err != nil {
 			return nil, err
 		}
 		return elem, nil
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return t.mapNonData(orig)
 	default:
 		return orig, nil
 	}
 }
+
+// isNamedContainer reports whether typ is a named array, map, pointer, or
+// slice type. Such types can be recursive, e. g., type M map[string]M.
+func isNamedContainer(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Array, reflect.Map, reflect.Ptr, reflect.Slice:
+		return typ.Name() != ""
+	default:
+		return false
+	}
+}
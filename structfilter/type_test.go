@@ -68,7 +68,7 @@ func TestSimpleStructReflectType(t *testing.T) {
 			filtered.Kind())
 	}
 	if filtered.NumField() != origType.NumField() {
-		t.Fatal("Expected orig and filtered struct to have same number of fields "+
+		t.Fatalf("Expected orig and filtered struct to have same number of fields "+
 			"with empty filter, got %d != %d",
 			origType.NumField(), filtered.NumField())
 	}
@@ -110,11 +110,39 @@ func TestRecursiveStructReflectType(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error filtering recursive structure: %s", err)
 	}
+	expected := map[string]reflect.Type{
+		"Array": reflect.ArrayOf(42, recursiveType),
+		"Map":   recursiveType,
+		"Ptr":   recursiveType,
+		"Slice": reflect.SliceOf(recursiveType),
+	}
 	for i := 0; i != filtered.NumField(); i++ {
 		field := filtered.Field(i)
-		if field.Type != interfaceType {
-			t.Errorf("Type of field '%s' of recursive struct is not interface{}",
-				field.Name)
+		if field.Type != expected[field.Name] {
+			t.Errorf("Type of field '%s' of recursive struct is %s, expected %s",
+				field.Name, field.Type, expected[field.Name])
+		}
+	}
+}
+
+// TestRecursionStrategies tests the ReflectType method with the different
+// recursion strategies.
+func TestRecursionStrategies(t *testing.T) {
+	for recursion, cutType := range map[Recursion]reflect.Type{
+		RecursionInterface: recursiveType,
+		RecursionJSON:      recursiveJSONType,
+		RecursionMap:       recursiveMapType,
+	} {
+		filter := New()
+		filter.SetRecursion(recursion)
+		filtered, err := filter.ReflectType(reflect.TypeOf(RecursiveStruct{}))
+		if err != nil {
+			t.Fatalf("Error filtering recursive structure: %s", err)
+		}
+		field, _ := filtered.FieldByName("Ptr")
+		if field.Type != cutType {
+			t.Errorf("Expected cut type %s for strategy %d, got %s",
+				cutType, recursion, field.Type)
 		}
 	}
 }
@@ -143,7 +171,7 @@ func TestNestedReflectType(t *testing.T) {
 		t.Fatalf("Error filtering nested struct: %s", err)
 	}
 	if filtered.NumField() != origType.NumField() {
-		t.Fatal("Expected orig and filtered struct to have same number of fields "+
+		t.Fatalf("Expected orig and filtered struct to have same number of fields "+
 			"with empty filter, got %d != %d",
 			origType.NumField(), filtered.NumField())
 	}
@@ -155,3 +183,41 @@ func TestNestedReflectType(t *testing.T) {
 		}
 	}
 }
+
+// TestMapType tests that MapType returns the types of converted values.
+func TestMapType(t *testing.T) {
+	filter := New(When(NameMatches(Exact("Token")), removeFilter))
+	filter.SetNonData(NonDataDrop)
+	lead := &testTeam.Lead
+	for _, v := range []interface{}{
+		testTeam, &lead, []Member{*lead}, map[string]*Member{"a": lead},
+		[2]Team{}, 42, "x", []int{1}, testTree(),
+	} {
+		origType := reflect.TypeOf(v)
+		mapped, err := filter.MapType(origType)
+		if err != nil {
+			t.Errorf("Error mapping %s: %s", origType, err)
+			continue
+		}
+		converted, err := filter.Convert(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if convertedType := reflect.TypeOf(converted); mapped != convertedType {
+			t.Errorf("Expected %s to map to %s, got %s",
+				origType, convertedType, mapped)
+		}
+	}
+	mapped, err := filter.MapType(reflect.TypeOf(make(chan int)))
+	if mapped != nil || err != nil {
+		t.Errorf("Expected dropped type to map to nil, got %v/%v", mapped, err)
+	}
+	if _, err = filter.MapType(nil); err == nil {
+		t.Error("Expected error mapping nil")
+	}
+	mapped, err = filter.MapType(reflect.TypeOf(new(error)).Elem())
+	if mapped != interfaceType || err != nil {
+		t.Errorf("Expected interface to map to interface{}, got %v/%v",
+			mapped, err)
+	}
+}
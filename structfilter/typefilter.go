@@ -0,0 +1,118 @@
+package structfilter
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypeFunc is a function type for deciding on values by their original type.
+// Unlike filter functions, which decide on structure fields, type functions
+// apply to a type wherever it appears, even deep inside containers:
+//
+//   - ActionRedact maps the type to string. Non-nil values are converted to
+//     Redacted, nil values to the empty string. Note that redacted map keys
+//     collapse into a single entry.
+//   - ActionRemove removes values of the type. A structure field of the type,
+//     or of a pointer to it, is removed from the filtered structure. Slice
+//     elements and map entries are left out of converted slices and maps.
+//     Arrays of the type are converted to empty arrays. Interfaces holding
+//     the type are converted to nil.
+//
+// Type functions are called once per type, and their decisions are cached.
+// They must not depend on anything but the type.
+type TypeFunc func(typ reflect.Type) Action
+
+// TypeFilter returns a type function which takes the specified action on the
+// specified types.
+func TypeFilter(action Action, types ...reflect.Type) TypeFunc {
+	set := make(map[reflect.Type]bool, len(types))
+	for _, typ := range types {
+		set[typ] = true
+	}
+	return func(typ reflect.Type) Action {
+		if set[typ] {
+			return action
+		}
+		return ActionKeep
+	}
+}
+
+// SetTypeFilters sets the type functions. For each type, the first type
+// function returning an action other than ActionKeep decides what happens to
+// values of the type. If no type function does, values of the type are kept,
+// unless the type is sensitive, see T.RegisterSensitive. Setting the type
+// functions discards all filtered types created so far.
+func (t *T) SetTypeFilters(filters ...TypeFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(filters) == 0 {
+		t.typeFilter = nil
+	} else {
+		t.typeFilter = func(typ reflect.Type) Action {
+			for _, filter := range filters {
+				if action := filter(typ); action != ActionKeep {
+					return action
+				}
+			}
+			return ActionKeep
+		}
+	}
+	t.resetTypes()
+}
+
+// errRemoved signals that a type has to be dropped because a type function
+// or a sensitive type registration says so.
+var errRemoved = fmt.Errorf("removed by type %w", errDrop)
+
+// typeAction returns the action for the specified type. The action is
+// ActionKeep for types which are neither sensitive nor subject to a type
+// function.
+func (t *T) typeAction(typ reflect.Type) Action {
+	if action, ok := t.sensitive[typ]; ok {
+		return action
+	}
+	if action, ok := t.actions[typ]; ok {
+		return action
+	}
+	action := ActionKeep
+	if t.typeFilter != nil {
+		action = t.typeFilter(typ)
+	}
+	if action == ActionKeep && typ.Implements(sensitiveType) &&
+		isSensitive(typ) {
+		action = ActionRedact
+	}
+	t.actions[typ] = action
+	return action
+}
+
+// mapTypeAction maps an original type according to its action, which must
+// not be ActionKeep.
+func mapTypeAction(action Action) (reflect.Type, error) {
+	if action == ActionRemove {
+		return nil, errRemoved
+	}
+	return stringType, nil
+}
+
+// isRemovedValue reports whether value is removed by its type, see TypeFunc.
+// Values of pointer types are removed if their element type is removed, and
+// interfaces are removed if their dynamic value is removed.
+func (t *T) isRemovedValue(value reflect.Value) bool {
+	typ := value.Type()
+	switch typ.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return false
+		}
+		return t.isRemovedValue(value.Elem())
+	case reflect.Ptr:
+		for typ.Kind() == reflect.Ptr {
+			if t.typeAction(typ) == ActionRemove {
+				return true
+			}
+			typ = typ.Elem()
+		}
+	}
+	return t.typeAction(typ) == ActionRemove
+}
@@ -0,0 +1,109 @@
+package structfilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Token is a structure type for testing type functions.
+type Token struct {
+	Value string
+}
+
+// TokenHolder is a structure type for testing type functions.
+type TokenHolder struct {
+	Name     string
+	Token    Token
+	PToken   *Token
+	Tokens   []Token
+	PTokens  []*Token
+	Array    [2]Token
+	ByName   map[string]Token
+	ByToken  map[Token]int
+	Mixed    []interface{}
+	MixedMap map[string]interface{} `json:",omitempty"`
+	Others   []Token                `json:",omitempty"`
+}
+
+// TestTypeFilterRemove tests removing values by type deep inside containers.
+func TestTypeFilterRemove(t *testing.T) {
+	filter := New()
+	filter.SetTypeFilters(TypeFilter(ActionRemove, reflect.TypeOf(Token{})))
+	token := Token{Value: "t0k3n"}
+	holder := TokenHolder{
+		Name:     "holder",
+		Token:    token,
+		PToken:   &token,
+		Tokens:   []Token{token, token},
+		PTokens:  []*Token{&token, nil},
+		Array:    [2]Token{token, token},
+		ByName:   map[string]Token{"a": token},
+		ByToken:  map[Token]int{token: 1},
+		Mixed:    []interface{}{"keep", token, &token, 42},
+		MixedMap: map[string]interface{}{"t": token, "n": 1},
+		Others:   []Token{token},
+	}
+	converted, err := filter.Convert(holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Name":"holder","Tokens":[],"PTokens":[],"Array":[],` +
+		`"ByName":{},"ByToken":{},"Mixed":["keep",42],"MixedMap":{"n":1}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	encoded, err := filter.EncodeJSON(holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != expected {
+		t.Errorf("EncodeJSON: expected %s, got %s", expected, encoded)
+	}
+}
+
+// TestTypeFilterRedact tests redacting values by type deep inside
+// containers.
+func TestTypeFilterRedact(t *testing.T) {
+	filter := New()
+	filter.SetTypeFilters(
+		func(typ reflect.Type) Action {
+			if typ == reflect.TypeOf(Token{}) {
+				return ActionRedact
+			}
+			return ActionKeep
+		},
+	)
+	token := Token{Value: "t0k3n"}
+	converted, err := filter.Convert(TokenHolder{
+		Tokens: []Token{token},
+		Mixed:  []interface{}{token},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Name":"","Token":"[REDACTED]","PToken":null,` +
+		`"Tokens":["[REDACTED]"],"PTokens":null,` +
+		`"Array":["[REDACTED]","[REDACTED]"],"ByName":null,"ByToken":null,` +
+		`"Mixed":["[REDACTED]"]}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	// Sensitive type registrations take precedence.
+	filter.RegisterSensitive(reflect.TypeOf(Token{}), ActionKeep)
+	converted, err = filter.Convert(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.(struct{ Value string }).Value != token.Value {
+		t.Errorf("Expected token to be kept, got %v", converted)
+	}
+}
@@ -1,6 +1,8 @@
 package structfilter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"unsafe"
@@ -9,100 +11,238 @@ import (
 // Convert converts the specified input value to an output value based on the
 // filtered type of the dynamic type of the input. If in is nil, the return
 // value is (nil, nil). Maps, pointers, and slices whose type definition does
-// not involve a structure type will be copied shallowly. Struct fields not
+// not involve a structure or interface type will be copied shallowly, unless
+// limits or key filters require inspecting them. Struct fields not
 // present in the filtered type are dropped. ToValue also works with recursive
 // (self-referential) values.
 func (t *T) Convert(in interface{}) (interface{}, error) {
+	return t.ConvertContext(context.Background(), in)
+}
+
+// ConvertContext is like Convert, but checks ctx periodically while walking
+// the input value. If ctx is done before the conversion is complete,
+// ConvertContext returns ctx.Err(), wrapped with the path reached. Filter
+// functions can access ctx via Field.Context, but their decisions are cached
+// per profile, so per-request policy belongs into value hooks and string
+// functions, which see ctx on every conversion. If ctx carries a profile, see
+// WithProfile, in is converted for that profile.
+func (t *T) ConvertContext(
+	ctx context.Context, in interface{},
+) (interface{}, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.convertContext(ctx, in)
+}
+
+// convertContext converts in with ctx, see T.ConvertContext.
+func (t *T) convertContext(
+	ctx context.Context, in interface{},
+) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	origValue := reflect.ValueOf(in)
 	if !origValue.IsValid() {
 		return nil, nil
 	}
-	seenPointers := make(map[unsafe.Pointer]reflect.Value)
+	state := &convertState{
+		ctx:          ctx,
+		seenPointers: make(map[pointerKey]reflect.Value),
+	}
 	origType := origValue.Type()
-	filteredType, err := t.mapType(origType)
+	t.useRoot(profileFromContext(ctx), origType)
+	filteredType, err := t.mapType(ctx, "", origType)
+	if errors.Is(err, errDrop) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	filteredValue := reflect.New(filteredType).Elem()
-	if err = t.convertValue(seenPointers, origValue, filteredValue); err != nil {
+	if err = t.convertValue(
+		state, 0, "", origValue, filteredValue,
+	); err != nil {
 		return nil, err
 	}
 	return filteredValue.Interface(), nil
 }
 
+// convertState holds the state of a single value conversion.
+type convertState struct {
+	// ctx is the context of the conversion.
+	ctx context.Context
+
+	// seenPointers keeps track of converted pointer, map, and slice values, to
+	// properly convert recursive values and to preserve aliasing.
+	seenPointers map[pointerKey]reflect.Value
+
+	// nodes is the number of values visited so far.
+	nodes int
+}
+
+// pointerKey identifies an original pointer, map, or slice value converted
+// to a filtered type. Values with the same key alias each other, and so do
+// their converted values. Keying on the address alone would mix up unrelated
+// values sharing memory, such as a slice and a pointer to its first element,
+// or two slices sharing a backing array with different lengths. The filtered
+// type is part of the key because the same value may be reached via paths
+// with different filtered types, see Field.Path.
+type pointerKey struct {
+	// typ is the type of the value.
+	typ reflect.Type
+
+	// filtered is the filtered type the value is converted to.
+	filtered reflect.Type
+
+	// ptr is the address the value points to.
+	ptr unsafe.Pointer
+
+	// len is the length of a slice value, or -1 for other values.
+	len int
+}
+
+// newPointerKey returns the pointer key for the specified non-nil pointer,
+// map, or slice value converted to the specified filtered type.
+func newPointerKey(value reflect.Value, filtered reflect.Type) pointerKey {
+	key := pointerKey{
+		typ:      value.Type(),
+		filtered: filtered,
+		ptr:      unsafe.Pointer(value.Pointer()),
+		len:      -1,
+	}
+	if value.Kind() == reflect.Slice {
+		key.len = value.Len()
+	}
+	return key
+}
+
 // convertValue converts the specified original value to its filtered
-// counterpart and assigns it to filteredValue. The seenPointers map keeps
-// track of structure, map, and slice pointers, to properly convert recursive
-// values.
+// counterpart and assigns it to filteredValue. depth is the nesting depth of
+// origValue below the value passed to Convert, and path is its path, see
+// Field.Path.
 func (t *T) convertValue(
-	seenPointers map[unsafe.Pointer]reflect.Value,
+	state *convertState, depth int, path string,
 	origValue, filteredValue reflect.Value,
 ) error {
-	// If the original value is stored in an interface, we need to unwrap that
-	// first.
+	// Sensitive types may be interfaces, so they need to be handled first.
 	origType := origValue.Type()
+	if t.typeAction(origType) == ActionRedact {
+		if !isNilValue(origValue) {
+			redact(filteredValue)
+		}
+		return nil
+	}
+	// If the original value is stored in an interface, we need to unwrap that
+	// first. Its dynamic value starts a new path.
 	if origType.Kind() == reflect.Interface {
 		if !origValue.IsNil() {
-			return t.convertValue(seenPointers, origValue.Elem(), filteredValue)
+			return t.convertValue(
+				state, depth, "", origValue.Elem(), filteredValue,
+			)
 		}
 		return nil
 	}
-	// Common shortcut
+	// Recursion markers other than interfaces need special treatment.
 	filteredType := filteredValue.Type()
-	if origType == filteredType {
+	if filteredType == recursiveJSONType || filteredType == recursiveMapType {
+		return t.convertCut(state, depth, path, origValue, filteredValue)
+	}
+	if skip, err := t.checkNode(state, depth); skip || err != nil {
+		return err
+	}
+	// Common shortcut
+	if origType == filteredType && t.copyable(origType) {
 		filteredValue.Set(origValue)
 		return nil
 	}
-	// Avoid infinite recursion
-	switch origType.Kind() {
-	case reflect.Ptr, reflect.Slice, reflect.Map:
-		seenValue, ok := seenPointers[unsafe.Pointer(origValue.Pointer())]
-		if ok {
-			filteredValue.Set(seenValue)
-			return nil
-		}
-	}
-	// The filtered type may be interface{} to avoid a recursive type definition.
-	// In this case we need to allocate an actual value.
+	// The filtered type may be an interface type to avoid a recursive type
+	// definition. In this case we need to allocate an actual value.
 	oldFilteredValue := filteredValue
 	if filteredType.Kind() == reflect.Interface {
+		switch origType.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			if origValue.IsNil() {
+				return nil
+			}
+		}
 		var err error
-		filteredType, err = t.mapType(origType)
+		filteredType, err = t.mapType(state.ctx, path, origType)
+		if errors.Is(err, errDrop) {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 		filteredValue = reflect.New(filteredType).Elem()
 	}
+	// Avoid infinite recursion
+	switch origType.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		key := newPointerKey(origValue, filteredType)
+		if seenValue, ok := state.seenPointers[key]; ok {
+			oldFilteredValue.Set(seenValue)
+			return nil
+		}
+	}
 
 	switch origType.Kind() {
 	case reflect.Array:
-		for i := 0; i != origType.Len(); i++ {
+		for i := 0; i != filteredType.Len(); i++ {
 			origIndexValue := origValue.Index(i)
 			filteredIndexValue := filteredValue.Index(i)
 			if err := t.convertValue(
-				seenPointers, origIndexValue, filteredIndexValue,
+				state, depth+1, path, origIndexValue, filteredIndexValue,
 			); err != nil {
 				return fmt.Errorf("array[%d]: %w", i, err)
 			}
 		}
 	case reflect.Struct:
+		var redacted map[string]bool
+		if t.redacting {
+			profile := profileFromContext(state.ctx)
+			info := t.filteredInfo(profile, path, origType, filteredType)
+			if info != nil {
+				redacted = info.redacted
+			}
+		}
 		for i := 0; i != origType.NumField(); i++ {
 			origStructField := origType.Field(i)
 			if _, ok := filteredType.FieldByName(origStructField.Name); !ok {
 				continue
 			}
+			filteredFieldValue := filteredValue.FieldByName(origStructField.Name)
+			if redacted[origStructField.Name] {
+				filteredFieldValue.SetString(Redacted)
+				continue
+			}
 			if err := t.convertValue(
-				seenPointers, origValue.Field(i),
-				filteredValue.FieldByName(origStructField.Name),
+				state, depth+1, joinPath(path, origStructField.Name),
+				origValue.Field(i), filteredFieldValue,
 			); err != nil {
 				return fmt.Errorf("struct %s: %w", origStructField.Name, err)
 			}
 		}
+		if err := t.runValueHooks(state, origValue, filteredValue); err != nil {
+			return err
+		}
 	case reflect.Ptr, reflect.Slice, reflect.Map:
 		if !origValue.IsNil() {
-			seenPointers[unsafe.Pointer(origValue.Pointer())] = filteredValue
-			return t.convertPointer(seenPointers, origValue, filteredValue)
+			key := newPointerKey(origValue, filteredType)
+			state.seenPointers[key] = filteredValue
+			if err := t.convertPointer(
+				state, depth, path, origValue, filteredValue,
+			); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if err := t.convertString(
+			state, origValue, filteredValue,
+		); err != nil {
+			return err
 		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		t.convertNonData(origValue, filteredValue)
 	default:
 		filteredValue.Set(origValue)
 	}
@@ -110,57 +250,171 @@ func (t *T) convertValue(
 	return nil
 }
 
+// typeTraits describes properties of a type relevant to the conversion of its
+// values.
+type typeTraits struct {
+	// interfaces indicates that values of the type may contain interfaces.
+	interfaces bool
+
+	// stringKeys indicates that values of the type may contain maps with keys
+	// of string kind.
+	stringKeys bool
+
+	// unnamedStructs indicates that values of the type may contain values of
+	// unnamed structure types. The filtered type of such a structure type may
+	// be the original type even though fields are redacted.
+	unnamedStructs bool
+
+	// sensitive indicates that values of the type may contain values of
+	// sensitive types, see T.RegisterSensitive.
+	sensitive bool
+
+	// strings indicates that values of the type may contain values of string
+	// kind.
+	strings bool
+
+	// hooks indicates that values of the type may contain structure values
+	// with value hooks, see T.AddValueHook.
+	hooks bool
+}
+
+// traitsOf returns the traits of typ, which must not be recursive.
+func (t *T) traitsOf(typ reflect.Type) typeTraits {
+	if traits, ok := t.traits[typ]; ok {
+		return traits
+	}
+	var traits typeTraits
+	switch typ.Kind() {
+	case reflect.Array, reflect.Ptr, reflect.Slice:
+		traits = t.traitsOf(typ.Elem())
+	case reflect.Interface:
+		traits.interfaces = true
+	case reflect.Map:
+		key, elem := t.traitsOf(typ.Key()), t.traitsOf(typ.Elem())
+		traits.interfaces = key.interfaces || elem.interfaces
+		traits.stringKeys = key.stringKeys || elem.stringKeys ||
+			typ.Key().Kind() == reflect.String
+		traits.unnamedStructs = key.unnamedStructs || elem.unnamedStructs
+		traits.sensitive = key.sensitive || elem.sensitive
+		traits.strings = key.strings || elem.strings
+		traits.hooks = key.hooks || elem.hooks
+	case reflect.Struct:
+		traits.unnamedStructs = typ.Name() == ""
+		traits.hooks = len(t.hooks[typ]) != 0
+		for i := 0; i != typ.NumField(); i++ {
+			field := t.traitsOf(typ.Field(i).Type)
+			traits.interfaces = traits.interfaces || field.interfaces
+			traits.stringKeys = traits.stringKeys || field.stringKeys
+			traits.unnamedStructs = traits.unnamedStructs || field.unnamedStructs
+			traits.sensitive = traits.sensitive || field.sensitive
+			traits.strings = traits.strings || field.strings
+			traits.hooks = traits.hooks || field.hooks
+		}
+	case reflect.String:
+		traits.strings = true
+	}
+	traits.sensitive = traits.sensitive || t.typeAction(typ) != ActionKeep
+	t.traits[typ] = traits
+	return traits
+}
+
+// copyable reports whether values of typ, which must be its own filtered
+// type, can be copied shallowly. This is not the case if values of typ may
+// contain interfaces, whose dynamic values need filtering, or if the
+// conversion has to inspect values in full, e. g., because of limits or
+// redacted fields, sensitive types, string functions, or value hooks.
+func (t *T) copyable(typ reflect.Type) bool {
+	if t.limits != (Limits{}) {
+		return false
+	}
+	traits := t.traitsOf(typ)
+	return !traits.interfaces && !(traits.stringKeys && t.keyFilter != nil) &&
+		!(traits.unnamedStructs && t.redacting) && !traits.sensitive &&
+		!(traits.strings && t.stringFilter != nil) && !traits.hooks
+}
+
 // convertPointer converts the specified original value to the specified
 // filtered value. Both must have the same kind, which must be pointer, slice,
 // or map.
-// For info on seenPointers, see T.convertValue().
+// For info on state, depth, and path, see T.convertValue().
 func (t *T) convertPointer(
-	seenPointers map[unsafe.Pointer]reflect.Value,
+	state *convertState, depth int, path string,
 	origValue, filteredValue reflect.Value,
 ) error {
+	if err := state.ctx.Err(); err != nil {
+		return err
+	}
 	switch origValue.Kind() {
 	case reflect.Ptr:
 		filteredValue.Set(reflect.New(filteredValue.Type().Elem()))
 		if err := t.convertValue(
-			seenPointers, origValue.Elem(), filteredValue.Elem(),
+			state, depth+1, path, origValue.Elem(), filteredValue.Elem(),
 		); err != nil {
 			return fmt.Errorf("pointer: %w", err)
 		}
 	case reflect.Slice:
 		filteredElemType := filteredValue.Type().Elem()
-		for i := 0; i != origValue.Len(); i++ {
+		n, err := t.checkElements(state, origValue.Len())
+		if err != nil {
+			return err
+		}
+		filteredValue.Set(reflect.MakeSlice(filteredValue.Type(), 0, n))
+		for i := 0; i != n; i++ {
+			if t.isRemovedValue(origValue.Index(i)) {
+				continue
+			}
 			filteredElem := reflect.New(filteredElemType).Elem()
 			if err := t.convertValue(
-				seenPointers, origValue.Index(i), filteredElem,
+				state, depth+1, path, origValue.Index(i), filteredElem,
 			); err != nil {
 				return fmt.Errorf("slice[%d]: %w", i, err)
 			}
 			filteredValue.Set(reflect.Append(filteredValue, filteredElem))
 		}
+		if n != origValue.Len() {
+			t.markElements(filteredValue)
+		}
 	case reflect.Map:
+		n, err := t.checkElements(state, origValue.Len())
+		if err != nil {
+			return err
+		}
 		filteredType := filteredValue.Type()
-		filteredValue.Set(reflect.MakeMapWithSize(filteredType, origValue.Len()))
+		filteredValue.Set(reflect.MakeMapWithSize(filteredType, n))
 		filteredKeyType := filteredType.Key()
 		filteredElemType := filteredType.Elem()
 		iter := origValue.MapRange()
-		for iter.Next() {
+		for i := 0; i != n && iter.Next(); i++ {
 			origKeyValue := iter.Key()
+			action := t.keyAction(origKeyValue)
+			if action == ActionRemove {
+				continue
+			}
 			origElemValue := iter.Value()
+			if t.isRemovedValue(origKeyValue) ||
+				t.isRemovedValue(origElemValue) {
+				continue
+			}
 			filteredKeyValue := reflect.New(filteredKeyType).Elem()
 			filteredElemValue := reflect.New(filteredElemType).Elem()
 			if err := t.convertValue(
-				seenPointers, origKeyValue, filteredKeyValue,
+				state, depth+1, path, origKeyValue, filteredKeyValue,
 			); err != nil {
 				return fmt.Errorf("map[%v] key: %w", origKeyValue, err)
 			}
-			if err := t.convertValue(
-				seenPointers, origElemValue, filteredElemValue,
+			if action == ActionRedact {
+				redact(filteredElemValue)
+			} else if err := t.convertValue(
+				state, depth+1, path, origElemValue, filteredElemValue,
 			); err != nil {
 				return fmt.Errorf("map[%v] value %v: %w",
 					origKeyValue, origElemValue, err)
 			}
 			filteredValue.SetMapIndex(filteredKeyValue, filteredElemValue)
 		}
+		if n != origValue.Len() {
+			t.markElements(filteredValue)
+		}
 	}
 	return nil
 }
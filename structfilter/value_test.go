@@ -1,8 +1,12 @@
 package structfilter
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -110,3 +114,162 @@ func TestToValueInterface(t *testing.T) {
 		t.Error("Nested field Uint should have been removed")
 	}
 }
+
+// contextKey is a context key type for testing.
+type contextKey struct{}
+
+// TestConvertContext tests value filtering with a context.
+func TestConvertContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(
+		context.WithValue(context.Background(), contextKey{}, "policy"))
+	defer cancel()
+	filter := New(func(f *Field) error {
+		if f.Context().Value(contextKey{}) != "policy" {
+			t.Error("Expected filter to see conversion context")
+		}
+		if f.Name() == "Remove1" {
+			cancel()
+		}
+		return nil
+	})
+	type items struct {
+		Item1 interface{}
+		Item2 interface{}
+	}
+	orig := items{
+		Item1: &StructKeepRemove{},
+		Item2: &StructKeepRemove{},
+	}
+	_, err := filter.ConvertContext(ctx, orig)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context cancellation, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "Item1") {
+		t.Errorf("Expected path in error, got: %s", err)
+	}
+	if _, err = filter.ConvertContext(ctx, 42); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context cancellation, got: %v", err)
+	}
+}
+
+// TestConvertBackgroundContext tests that filters see a background context
+// with Convert.
+func TestConvertBackgroundContext(t *testing.T) {
+	filter := New(func(f *Field) error {
+		if f.Context() == nil {
+			t.Error("Expected non-nil context")
+		}
+		return nil
+	})
+	if _, err := filter.Convert(StructKeepRemove{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConvertContextNotCached tests that the type cache does not retain the
+// context of a conversion.
+func TestConvertContextNotCached(t *testing.T) {
+	filter := New()
+	ctx := context.WithValue(context.Background(), contextKey{}, "request")
+	if _, err := filter.ConvertContext(ctx, StructKeepRemove{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, types := range filter.types {
+		for key, info := range types {
+			for _, fi := range info.fields {
+				if fi.field.ctx != nil {
+					t.Errorf("Context of field %s in %s retained",
+						fi.field.name, key.typ)
+				}
+			}
+		}
+	}
+}
+
+// AliasInner is a structure type for testing aliasing.
+type AliasInner struct {
+	Value  int
+	Secret string
+}
+
+// AliasStruct is a structure type for testing aliasing.
+type AliasStruct struct {
+	Slice []AliasInner
+	Short []AliasInner
+	First *AliasInner
+	Ptr1  *AliasInner
+	Ptr2  *AliasInner
+	Map1  map[string]*AliasInner
+	Map2  map[string]*AliasInner
+}
+
+// TestToValueAliasing tests that shared values are converted to shared
+// filtered values, and that unrelated values sharing memory are kept apart.
+func TestToValueAliasing(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile("^Secret$")))
+	backing := []AliasInner{{Value: 1}, {Value: 2}, {Value: 3}}
+	shared := &AliasInner{Value: 4}
+	sharedMap := map[string]*AliasInner{"shared": shared}
+	orig := &AliasStruct{
+		Slice: backing,
+		Short: backing[:1],
+		First: &backing[0],
+		Ptr1:  shared,
+		Ptr2:  shared,
+		Map1:  sharedMap,
+		Map2:  sharedMap,
+	}
+	filtered, err := filter.Convert(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := reflect.ValueOf(filtered).Elem()
+	if n := value.FieldByName("Slice").Len(); n != 3 {
+		t.Errorf("Expected 3 slice elements, got %d", n)
+	}
+	if n := value.FieldByName("Short").Len(); n != 1 {
+		t.Errorf("Expected 1 element in shorter slice, got %d", n)
+	}
+	first := value.FieldByName("First")
+	if first.Kind() != reflect.Ptr ||
+		first.Elem().FieldByName("Value").Interface().(int) != 1 {
+		t.Errorf("Pointer to first slice element mixed up: %v", first)
+	}
+	ptr1, ptr2 := value.FieldByName("Ptr1"), value.FieldByName("Ptr2")
+	if ptr1.Pointer() != ptr2.Pointer() {
+		t.Error("Expected shared pointer to be converted to shared pointer")
+	}
+	map1, map2 := value.FieldByName("Map1"), value.FieldByName("Map2")
+	if map1.Pointer() != map2.Pointer() {
+		t.Error("Expected shared map to be converted to shared map")
+	}
+	if map1.MapIndex(reflect.ValueOf("shared")).Pointer() != ptr1.Pointer() {
+		t.Error("Expected pointer in shared map to be shared as well")
+	}
+}
+
+// TestConvertConcurrent tests using a structure filter from several
+// goroutines at once. Run with the race detector.
+func TestConvertConcurrent(t *testing.T) {
+	filter := New(RemoveFieldFilter(regexp.MustCompile("^Remove.*$")))
+	var wg sync.WaitGroup
+	for i := 0; i != 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j != 16; j++ {
+				if _, err := filter.Convert(&StructKeepRemove{Keep1: i}); err != nil {
+					t.Error(err)
+				}
+				if _, err := filter.EncodeJSON(StructKeepRemove{}); err != nil {
+					t.Error(err)
+				}
+				if j%4 == i%4 {
+					filter.Reset()
+				}
+				filter.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+}